@@ -0,0 +1,514 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"image"
+	"image/color/palette"
+	"image/draw"
+	"image/gif"
+	"image/png"
+	"log"
+	"math"
+	"os"
+	"regexp"
+	"sync"
+	"time"
+
+	"github.com/chromedp/cdproto/cdp"
+	"github.com/chromedp/cdproto/emulation"
+	"github.com/chromedp/cdproto/page"
+	"github.com/chromedp/chromedp"
+)
+
+const (
+	AnimationFormatGif  = "gif"
+	AnimationFormatWebp = "webp"
+)
+
+const (
+	defaultAnimationFrameRate = 10
+	maxAnimationFrameRate     = 30
+	defaultAnimationDuration  = 5
+	maxAnimationDuration      = 30
+	defaultAnimationWidth     = 1024
+	defaultAnimationHeight    = 768
+	maxAnimationSize          = 1920
+	// maxAnimationPixels limits the width times the height times the number of frames, the frames of a gif are kept
+	// in memory until it is written
+	maxAnimationPixels = 200_000_000
+)
+
+// scrollRangeScript returns how far the page can scroll down
+const scrollRangeScript = `Math.max(0, document.documentElement.scrollHeight - window.innerHeight)`
+
+// AnimationRequest records a page while it loads, and scrolls it to the bottom when Scroll is set
+type AnimationRequest struct {
+	Data     string `json:"data" form:"data"`
+	Download bool   `json:"download" form:"download"`
+	// Format is gif (default) or webp
+	Format    string `json:"format" form:"format"`
+	FrameRate int    `json:"frameRate" form:"frameRate"`
+	// Duration is the length of the recording in seconds
+	Duration float64 `json:"duration" form:"duration"`
+	Width    int64   `json:"width" form:"width"`
+	Height   int64   `json:"height" form:"height"`
+	Scroll   bool    `json:"scroll" form:"scroll"`
+	// Quality applies to webp animations, without it the frames are lossless
+	Quality int `json:"quality" form:"quality"`
+}
+
+type AnimationResponse struct {
+	Animation string  `json:"animation"`
+	Url       string  `json:"url"`
+	Format    string  `json:"format"`
+	Frames    int     `json:"frames"`
+	Duration  float64 `json:"duration"`
+}
+
+// AnimationReturn is a recorded animation file, its frame count and length in seconds
+type AnimationReturn struct {
+	OutputFile *os.File
+	Frames     int
+	Duration   float64
+}
+
+// validateAnimationRequest checks the request and fills in the defaults
+func validateAnimationRequest(animationRequest *AnimationRequest, options *ServerOptions) error {
+	switch animationRequest.Format {
+	case "":
+		animationRequest.Format = AnimationFormatGif
+	case AnimationFormatGif:
+	case AnimationFormatWebp:
+		if options.AnimationEncoder == nil {
+			return errors.New("webp animations are unavailable, install libwebp-tools")
+		}
+	default:
+		return fmt.Errorf("invalid animation format %q, expected gif or webp", animationRequest.Format)
+	}
+
+	if animationRequest.FrameRate == 0 {
+		animationRequest.FrameRate = defaultAnimationFrameRate
+	}
+
+	if animationRequest.FrameRate < 1 || animationRequest.FrameRate > maxAnimationFrameRate {
+		return fmt.Errorf("frame rate must be between 1 and %d", maxAnimationFrameRate)
+	}
+
+	if animationRequest.Duration == 0 {
+		animationRequest.Duration = defaultAnimationDuration
+	}
+
+	if animationRequest.Duration < 0 || animationRequest.Duration > maxAnimationDuration {
+		return fmt.Errorf("duration must be between 0 and %d seconds", maxAnimationDuration)
+	}
+
+	if animationRequest.Width == 0 {
+		animationRequest.Width = defaultAnimationWidth
+	}
+
+	if animationRequest.Height == 0 {
+		animationRequest.Height = defaultAnimationHeight
+	}
+
+	if animationRequest.Width < 1 || animationRequest.Width > maxAnimationSize || animationRequest.Height < 1 || animationRequest.Height > maxAnimationSize {
+		return fmt.Errorf("width and height must be between 1 and %d pixels", maxAnimationSize)
+	}
+
+	frames := int64(math.Ceil(animationRequest.Duration * float64(animationRequest.FrameRate)))
+	if animationRequest.Width*animationRequest.Height*frames > maxAnimationPixels {
+		return fmt.Errorf("the animation is limited to %d pixels across its frames, lower the size, frame rate or duration", maxAnimationPixels)
+	}
+
+	if animationRequest.Quality < 0 || animationRequest.Quality > 100 {
+		return errors.New("quality must be between 1 and 100")
+	}
+
+	return nil
+}
+
+// buildAnimation records the page with a screencast and encodes the frames into an animation in the png directory
+func buildAnimation(animationRequest *AnimationRequest, serverOptions *ServerOptions) (*AnimationReturn, error) {
+	if err := validateAnimationRequest(animationRequest, serverOptions); err != nil {
+		return nil, err
+	}
+
+	var requestUrl string
+	match, _ := regexp.MatchString("(?i)^(https?|file|data):", animationRequest.Data)
+	if match {
+		requestUrl = animationRequest.Data
+	} else {
+		requestUrl = "data:text/html;base64," + base64.StdEncoding.EncodeToString([]byte(animationRequest.Data))
+	}
+
+	var opts []chromedp.ContextOption
+	opts = append(opts, chromedp.WithLogf(log.Printf))
+	opts = append(opts, chromedp.WithErrorf(log.Printf))
+
+	if serverOptions.Debug {
+		opts = append(opts, chromedp.WithDebugf(log.Printf))
+	}
+
+	serverOptions.TabPool.Acquire()
+	defer serverOptions.TabPool.Release()
+
+	allocatorContext, _ := chromedp.NewRemoteAllocator(context.Background(), "ws://"+serverOptions.ChromeUri)
+
+	ctx, cancel := chromedp.NewContext(allocatorContext, opts...)
+	defer cancel()
+
+	outputFile, err := os.CreateTemp(*serverOptions.DirectoryMap[DirectoryKeyPng], "*."+animationRequest.Format)
+	if err != nil {
+		return nil, errors.New("unable to create output file")
+	}
+	defer outputFile.Close()
+
+	var encoder animationEncoder
+	if animationRequest.Format == AnimationFormatWebp {
+		encoder = newWebpAnimationEncoder(outputFile.Name(), animationRequest.Quality, serverOptions)
+	} else {
+		encoder = newGifAnimationEncoder(outputFile)
+	}
+	defer encoder.Close()
+
+	frameCount, duration, err := recordScreencast(ctx, requestUrl, animationRequest, encoder)
+	if err == nil {
+		if err = encoder.Encode(); err != nil {
+			err = fmt.Errorf("unable to encode animation: %w", err)
+		}
+	}
+	if err != nil {
+		os.Remove(outputFile.Name())
+		return nil, err
+	}
+
+	return &AnimationReturn{OutputFile: outputFile, Frames: frameCount, Duration: math.Round(duration.Seconds()*100) / 100}, nil
+}
+
+// recordScreencast navigates to requestUrl while Chrome streams frames, and passes the frames sampled at the frame
+// rate to the encoder as they arrive. It returns how many frames were added and their total duration.
+func recordScreencast(ctx context.Context, requestUrl string, animationRequest *AnimationRequest, encoder animationEncoder) (int, time.Duration, error) {
+	// Open the tab before listening to it
+	if err := chromedp.Run(ctx, emulation.SetDeviceMetricsOverride(animationRequest.Width, animationRequest.Height, 1, false)); err != nil {
+		return 0, 0, err
+	}
+
+	start := time.Now()
+	end := start.Add(time.Duration(animationRequest.Duration * float64(time.Second)))
+	sampler := newScreencastSampler(start, animationRequest, encoder)
+
+	chromedp.ListenTarget(ctx, func(ev interface{}) {
+		frameEvent, ok := ev.(*page.EventScreencastFrame)
+		if !ok {
+			return
+		}
+
+		if data, err := base64.StdEncoding.DecodeString(frameEvent.Data); err == nil {
+			sampler.Add(data, time.Now())
+		}
+
+		// Chrome waits for every frame to be acknowledged before sending the next one
+		go func() {
+			c := chromedp.FromContext(ctx)
+			if err := page.ScreencastFrameAck(frameEvent.SessionID).Do(cdp.WithExecutor(ctx, c.Target)); err != nil {
+				log.Printf("Unable to acknowledge screencast frame: %s", err.Error())
+			}
+		}()
+	})
+
+	if err := runScreencast(ctx, requestUrl, animationRequest, end); err != nil {
+		sampler.Finish()
+		return 0, 0, err
+	}
+
+	return sampler.Finish()
+}
+
+// runScreencast streams the page from the moment it is navigated to until end
+func runScreencast(ctx context.Context, requestUrl string, animationRequest *AnimationRequest, end time.Time) error {
+	err := chromedp.Run(ctx,
+		page.StartScreencast().WithFormat(page.ScreencastFormatPng).WithMaxWidth(animationRequest.Width).WithMaxHeight(animationRequest.Height),
+		chromedp.Navigate(requestUrl),
+	)
+	if err != nil {
+		return err
+	}
+
+	if animationRequest.Scroll {
+		if err := scrollPage(ctx, end, animationRequest.FrameRate); err != nil {
+			return err
+		}
+	}
+
+	time.Sleep(time.Until(end))
+
+	return chromedp.Run(ctx, page.StopScreencast())
+}
+
+// scrollPage scrolls to the bottom of the page in even steps so that it arrives at end
+func scrollPage(ctx context.Context, end time.Time, frameRate int) error {
+	var scrollRange float64
+	if err := chromedp.Run(ctx, chromedp.Evaluate(scrollRangeScript, &scrollRange)); err != nil {
+		return fmt.Errorf("unable to scroll: %w", err)
+	}
+
+	interval := time.Second / time.Duration(frameRate)
+	steps := int(time.Until(end) / interval)
+	if steps < 1 || scrollRange <= 0 {
+		return nil
+	}
+
+	for step := 1; step <= steps; step++ {
+		script := fmt.Sprintf("window.scrollTo(0, %f)", scrollRange*float64(step)/float64(steps))
+		if err := chromedp.Run(ctx, chromedp.Evaluate(script, nil)); err != nil {
+			return fmt.Errorf("unable to scroll: %w", err)
+		}
+
+		time.Sleep(interval)
+	}
+
+	return nil
+}
+
+// sampledFrame is a frame of the animation and how long it is shown
+type sampledFrame struct {
+	Data     []byte
+	Duration time.Duration
+}
+
+// screencastSampler keeps the frame on screen at every tick of the frame rate. Chrome only sends frames when the page
+// changes, so a frame that stays on screen is shown for longer instead of being repeated, and frames replaced before
+// the next tick are dropped as they arrive. A frame is handed to the encoder once the next one shows how long it lasts.
+type screencastSampler struct {
+	start    time.Time
+	interval time.Duration
+	ticks    int
+
+	mutex sync.Mutex
+	// pending is the last frame that arrived and pendingTick the first tick it is on screen at
+	pending     []byte
+	pendingTick int
+	queued      int
+	pixels      int64
+	finished    bool
+	err         error
+
+	frames chan sampledFrame
+	done   chan struct{}
+	added  int
+	total  time.Duration
+}
+
+// newScreencastSampler starts passing the sampled frames to the encoder, Finish has to be called to stop it
+func newScreencastSampler(start time.Time, animationRequest *AnimationRequest, encoder animationEncoder) *screencastSampler {
+	ticks := max(1, int(animationRequest.Duration*float64(animationRequest.FrameRate)))
+	sampler := &screencastSampler{
+		start:    start,
+		interval: time.Second / time.Duration(animationRequest.FrameRate),
+		ticks:    ticks,
+		// Every tick adds a frame at most, plus the last frame of a page that only painted after the last tick
+		frames: make(chan sampledFrame, ticks+1),
+		done:   make(chan struct{}),
+	}
+
+	// Encoding happens apart from the listener so Chrome's events are not held up
+	go func() {
+		defer close(sampler.done)
+
+		var err error
+		for frame := range sampler.frames {
+			if err != nil {
+				continue
+			}
+
+			if err = encoder.AddFrame(frame.Data, frame.Duration); err != nil {
+				err = fmt.Errorf("unable to encode animation: %w", err)
+				sampler.setError(err)
+				continue
+			}
+
+			sampler.added++
+			sampler.total += frame.Duration
+		}
+	}()
+
+	return sampler
+}
+
+// tick returns the first tick at or after at
+func (sampler *screencastSampler) tick(at time.Time) int {
+	elapsed := at.Sub(sampler.start)
+	if elapsed <= 0 {
+		return 0
+	}
+
+	return int((elapsed + sampler.interval - 1) / sampler.interval)
+}
+
+// Add records a frame that arrived at at, the pending frame is passed on when a tick shows it
+func (sampler *screencastSampler) Add(data []byte, at time.Time) {
+	sampler.mutex.Lock()
+	defer sampler.mutex.Unlock()
+
+	if sampler.finished || sampler.err != nil {
+		return
+	}
+
+	tick := sampler.tick(at)
+	if sampler.pending != nil && tick > sampler.pendingTick && sampler.pendingTick < sampler.ticks {
+		sampler.addFrame(sampler.pending, min(tick, sampler.ticks)-sampler.pendingTick)
+	}
+
+	sampler.pending = data
+	sampler.pendingTick = tick
+}
+
+// Finish passes on the last frame, waits for the encoder and returns how many frames it added and their duration
+func (sampler *screencastSampler) Finish() (int, time.Duration, error) {
+	sampler.mutex.Lock()
+	if !sampler.finished {
+		sampler.finished = true
+
+		switch {
+		case sampler.err != nil:
+		case sampler.pending == nil:
+			sampler.err = errors.New("no frames were recorded")
+		case sampler.pendingTick < sampler.ticks:
+			sampler.addFrame(sampler.pending, sampler.ticks-sampler.pendingTick)
+		case sampler.queued == 0:
+			// Frames that arrived after the last tick still show the final state of the page
+			sampler.addFrame(sampler.pending, 1)
+		}
+		sampler.pending = nil
+
+		close(sampler.frames)
+	}
+	sampler.mutex.Unlock()
+
+	<-sampler.done
+
+	sampler.mutex.Lock()
+	defer sampler.mutex.Unlock()
+
+	if sampler.err != nil {
+		return 0, 0, sampler.err
+	}
+
+	return sampler.added, sampler.total, nil
+}
+
+// addFrame queues a frame shown for ticks, failing once the frames add up to more than maxAnimationPixels
+func (sampler *screencastSampler) addFrame(data []byte, ticks int) {
+	config, err := png.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		sampler.err = fmt.Errorf("unable to read frame: %w", err)
+		return
+	}
+
+	sampler.pixels += int64(config.Width) * int64(config.Height)
+	if sampler.pixels > maxAnimationPixels {
+		sampler.err = fmt.Errorf("the animation is larger than %d pixels across its frames", maxAnimationPixels)
+		return
+	}
+
+	sampler.queued++
+	sampler.frames <- sampledFrame{Data: data, Duration: time.Duration(ticks) * sampler.interval}
+}
+
+func (sampler *screencastSampler) setError(err error) {
+	sampler.mutex.Lock()
+	defer sampler.mutex.Unlock()
+
+	if sampler.err == nil {
+		sampler.err = err
+	}
+}
+
+// animationEncoder receives the png frames of an animation one at a time
+type animationEncoder interface {
+	// AddFrame appends a frame that is shown for duration
+	AddFrame(data []byte, duration time.Duration) error
+	// Encode writes the animation once every frame was added
+	Encode() error
+	// Close removes the temporary files of the encoder
+	Close()
+}
+
+// gifAnimationEncoder dithers every frame to the plan9 palette as it is added and writes an endlessly looping gif
+type gifAnimationEncoder struct {
+	animation  *gif.GIF
+	outputFile *os.File
+}
+
+func newGifAnimationEncoder(outputFile *os.File) *gifAnimationEncoder {
+	return &gifAnimationEncoder{animation: &gif.GIF{LoopCount: 0}, outputFile: outputFile}
+}
+
+func (encoder *gifAnimationEncoder) AddFrame(data []byte, duration time.Duration) error {
+	decoded, err := png.Decode(bytes.NewReader(data))
+	if err != nil {
+		return fmt.Errorf("unable to read frame: %w", err)
+	}
+
+	// Only the paletted frame is kept, it takes a quarter of the memory of the decoded one
+	bounds := decoded.Bounds()
+	paletted := image.NewPaletted(image.Rect(0, 0, bounds.Dx(), bounds.Dy()), palette.Plan9)
+	draw.FloydSteinberg.Draw(paletted, paletted.Bounds(), decoded, bounds.Min)
+
+	// gif delays are in hundredths of a second, browsers slow down delays shorter than 2
+	encoder.animation.Image = append(encoder.animation.Image, paletted)
+	encoder.animation.Delay = append(encoder.animation.Delay, max(2, int(duration.Round(10*time.Millisecond)/(10*time.Millisecond))))
+
+	return nil
+}
+
+func (encoder *gifAnimationEncoder) Encode() error {
+	return gif.EncodeAll(encoder.outputFile, encoder.animation)
+}
+
+func (encoder *gifAnimationEncoder) Close() {}
+
+// webpAnimationEncoder writes every frame to a png file as it is added and assembles them with img2webp
+type webpAnimationEncoder struct {
+	frameFiles []string
+	durations  []int
+	outputFile string
+	quality    int
+	options    *ServerOptions
+}
+
+func newWebpAnimationEncoder(outputFile string, quality int, options *ServerOptions) *webpAnimationEncoder {
+	return &webpAnimationEncoder{outputFile: outputFile, quality: quality, options: options}
+}
+
+func (encoder *webpAnimationEncoder) AddFrame(data []byte, duration time.Duration) error {
+	frameFile, err := os.CreateTemp(*encoder.options.DirectoryMap[DirectoryKeyPreview], "*-frame.png")
+	if err != nil {
+		return errors.New("unable to create frame file")
+	}
+	encoder.frameFiles = append(encoder.frameFiles, frameFile.Name())
+
+	// Chrome sends png frames, so they are written as they are
+	_, err = frameFile.Write(data)
+	frameFile.Close()
+	if err != nil {
+		return err
+	}
+
+	encoder.durations = append(encoder.durations, int(duration.Milliseconds()))
+
+	return nil
+}
+
+func (encoder *webpAnimationEncoder) Encode() error {
+	return encoder.options.AnimationEncoder.Encode(encoder.frameFiles, encoder.durations, encoder.outputFile, encoder.quality)
+}
+
+func (encoder *webpAnimationEncoder) Close() {
+	for _, frameFile := range encoder.frameFiles {
+		os.Remove(frameFile)
+	}
+}
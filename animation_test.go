@@ -0,0 +1,110 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/png"
+	"slices"
+	"testing"
+	"time"
+)
+
+// testAnimationEncoder records the durations of the frames it is given
+type testAnimationEncoder struct {
+	durations []time.Duration
+	err       error
+}
+
+func (encoder *testAnimationEncoder) AddFrame(data []byte, duration time.Duration) error {
+	encoder.durations = append(encoder.durations, duration)
+	return encoder.err
+}
+
+func (encoder *testAnimationEncoder) Encode() error {
+	return nil
+}
+
+func (encoder *testAnimationEncoder) Close() {}
+
+func newTestFrame(t *testing.T, width int, height int) []byte {
+	t.Helper()
+
+	var encoded bytes.Buffer
+	if err := png.Encode(&encoded, image.NewGray(image.Rect(0, 0, width, height))); err != nil {
+		t.Fatal(err)
+	}
+
+	return encoded.Bytes()
+}
+
+func TestScreencastSampler(t *testing.T) {
+	ms := time.Millisecond
+
+	tests := []struct {
+		name      string
+		arrivals  []time.Duration
+		durations []time.Duration
+	}{
+		{name: "frame held until the next one", arrivals: []time.Duration{0, 350 * ms}, durations: []time.Duration{400 * ms, 600 * ms}},
+		{name: "frames between ticks are dropped", arrivals: []time.Duration{0, 110 * ms, 120 * ms, 130 * ms, 500 * ms}, durations: []time.Duration{200 * ms, 300 * ms, 500 * ms}},
+		{name: "starts with the first frame", arrivals: []time.Duration{250 * ms}, durations: []time.Duration{700 * ms}},
+		{name: "only frames after the last tick", arrivals: []time.Duration{2 * time.Second, 3 * time.Second}, durations: []time.Duration{100 * ms}},
+	}
+
+	frame := newTestFrame(t, 4, 4)
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			encoder := &testAnimationEncoder{}
+			start := time.Now()
+			sampler := newScreencastSampler(start, &AnimationRequest{FrameRate: 10, Duration: 1}, encoder)
+
+			for _, arrival := range test.arrivals {
+				sampler.Add(frame, start.Add(arrival))
+			}
+
+			frames, duration, err := sampler.Finish()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if !slices.Equal(encoder.durations, test.durations) || frames != len(test.durations) {
+				t.Errorf("durations = %v, want %v", encoder.durations, test.durations)
+			}
+
+			var want time.Duration
+			for _, frameDuration := range test.durations {
+				want += frameDuration
+			}
+			if duration != want {
+				t.Errorf("duration = %v, want %v", duration, want)
+			}
+		})
+	}
+}
+
+func TestScreencastSamplerErrors(t *testing.T) {
+	start := time.Now()
+
+	if _, _, err := newScreencastSampler(start, &AnimationRequest{FrameRate: 10, Duration: 1}, &testAnimationEncoder{}).Finish(); err == nil {
+		t.Error("expected an error without frames")
+	}
+
+	// Frames larger than the window add up past the limit
+	sampler := newScreencastSampler(start, &AnimationRequest{FrameRate: 10, Duration: 1}, &testAnimationEncoder{})
+	large := newTestPngHeader(8000, 8000)
+	for tick := range 10 {
+		sampler.Add(large, start.Add(time.Duration(tick)*100*time.Millisecond))
+	}
+
+	if _, _, err := sampler.Finish(); err == nil {
+		t.Error("expected an error past the pixel limit")
+	}
+
+	encoder := &testAnimationEncoder{err: errors.New("disk full")}
+	sampler = newScreencastSampler(start, &AnimationRequest{FrameRate: 10, Duration: 1}, encoder)
+	sampler.Add(newTestFrame(t, 4, 4), start)
+	if _, _, err := sampler.Finish(); err == nil {
+		t.Error("expected the encoder error")
+	}
+}
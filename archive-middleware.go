@@ -0,0 +1,19 @@
+package main
+
+import (
+	"path"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ArchiveHeadersMiddleware serves archives as downloads in a sandbox. HTML archives hold the scripts of the page that
+// was archived, opened from this server they would run with its origin.
+func ArchiveHeadersMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Header("Content-Disposition", "attachment; filename=\""+path.Base(c.Request.URL.Path)+"\"")
+		c.Header("Content-Security-Policy", "sandbox")
+		c.Header("X-Content-Type-Options", "nosniff")
+
+		c.Next()
+	}
+}
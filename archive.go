@@ -0,0 +1,119 @@
+package main
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"log"
+	"mime"
+	"os"
+	"regexp"
+
+	"github.com/chromedp/cdproto/page"
+	"github.com/chromedp/chromedp"
+)
+
+const (
+	ArchiveFormatMhtml = "mhtml"
+	ArchiveFormatHtml  = "html"
+)
+
+// serializeDocumentScript returns the document as it is after its scripts ran, doctype included
+const serializeDocumentScript = `(document.doctype ? new XMLSerializer().serializeToString(document.doctype) + "\n" : "") + document.documentElement.outerHTML`
+
+func init() {
+	// Web archives are not in every mime.types file
+	mime.AddExtensionType(".mhtml", "multipart/related")
+}
+
+type ArchiveRequest struct {
+	Data     string `json:"data" form:"data"`
+	Download bool   `json:"download" form:"download"`
+	// Format is mhtml (default), a web archive with every resource of the page, or html, the document alone
+	Format string `json:"format" form:"format"`
+}
+
+type ArchiveResponse struct {
+	Archive string `json:"archive"`
+	Url     string `json:"url"`
+	Format  string `json:"format"`
+	Title   string `json:"title"`
+}
+
+// ArchiveReturn is an archive file and the title of the archived document
+type ArchiveReturn struct {
+	OutputFile *os.File
+	Title      string
+}
+
+// buildArchive loads the page like printToPDF does and stores it as mhtml or html in the archives directory
+func buildArchive(archiveRequest *ArchiveRequest, serverOptions *ServerOptions) (*ArchiveReturn, error) {
+	switch archiveRequest.Format {
+	case "":
+		archiveRequest.Format = ArchiveFormatMhtml
+	case ArchiveFormatMhtml, ArchiveFormatHtml:
+	default:
+		return nil, fmt.Errorf("invalid archive format %q, expected mhtml or html", archiveRequest.Format)
+	}
+
+	var requestUrl string
+	match, _ := regexp.MatchString("(?i)^(https?|file|data):", archiveRequest.Data)
+	if match {
+		requestUrl = archiveRequest.Data
+	} else {
+		requestUrl = "data:text/html;base64," + base64.StdEncoding.EncodeToString([]byte(archiveRequest.Data))
+	}
+
+	var opts []chromedp.ContextOption
+	opts = append(opts, chromedp.WithLogf(log.Printf))
+	opts = append(opts, chromedp.WithErrorf(log.Printf))
+
+	if serverOptions.Debug {
+		opts = append(opts, chromedp.WithDebugf(log.Printf))
+	}
+
+	serverOptions.TabPool.Acquire()
+	defer serverOptions.TabPool.Release()
+
+	allocatorContext, _ := chromedp.NewRemoteAllocator(context.Background(), "ws://"+serverOptions.ChromeUri)
+
+	ctx, cancel := chromedp.NewContext(allocatorContext, opts...)
+	defer cancel()
+
+	var title string
+	var archive string
+	err := chromedp.Run(ctx,
+		chromedp.Navigate(requestUrl),
+		chromedp.Title(&title),
+		chromedp.ActionFunc(func(ctx context.Context) error {
+			if archiveRequest.Format == ArchiveFormatHtml {
+				return chromedp.Evaluate(serializeDocumentScript, &archive).Do(ctx)
+			}
+
+			var err error
+			archive, err = page.CaptureSnapshot().WithFormat(page.CaptureSnapshotFormatMhtml).Do(ctx)
+
+			return err
+		}),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(archive) == 0 {
+		return nil, errors.New("no archive returned")
+	}
+
+	outputFile, err := os.CreateTemp(*serverOptions.DirectoryMap[DirectoryKeyArchive], "*."+archiveRequest.Format)
+	if err != nil {
+		return nil, errors.New("unable to create output file")
+	}
+	defer outputFile.Close()
+
+	if _, err := outputFile.WriteString(archive); err != nil {
+		return nil, fmt.Errorf("unable to write output file: %w", err)
+	}
+
+	return &ArchiveReturn{OutputFile: outputFile, Title: title}, nil
+}
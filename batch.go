@@ -0,0 +1,207 @@
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+const maxBatchItems = 100
+
+// PngBatchRequest captures many sources. Every item is either the html or url to capture, or a PngRequest whose
+// options replace the defaults.
+type PngBatchRequest struct {
+	Defaults PngRequest        `json:"defaults"`
+	Items    []json.RawMessage `json:"items" swaggertype:"array,object"`
+	// Download returns a zip of every image instead of the json results
+	Download bool `json:"download"`
+}
+
+type PngBatchResponse struct {
+	Results []PngBatchResult `json:"results"`
+}
+
+// PngBatchResult is the outcome of one item, Status is the http status the item would have had on its own
+type PngBatchResult struct {
+	Index   int          `json:"index"`
+	Success bool         `json:"success"`
+	Status  int          `json:"status"`
+	Error   string       `json:"error,omitempty"`
+	Result  *PngResponse `json:"result,omitempty"`
+}
+
+// pngBatchCapture is the outcome of capturing one item
+type pngBatchCapture struct {
+	Format   string
+	Captures []pngCapture
+	Err      error
+}
+
+// getBatchItemRequest applies the options of an item to a copy of the defaults
+func getBatchItemRequest(defaults *PngRequest, item json.RawMessage) (*PngRequest, error) {
+	// The defaults are copied through json so that items do not share their pointers
+	encodedDefaults, err := json.Marshal(defaults)
+	if err != nil {
+		return nil, err
+	}
+
+	var itemRequest PngRequest
+	if err := json.Unmarshal(encodedDefaults, &itemRequest); err != nil {
+		return nil, err
+	}
+
+	trimmedItem := bytes.TrimSpace(item)
+	if len(trimmedItem) > 0 && trimmedItem[0] == '"' {
+		if err := json.Unmarshal(trimmedItem, &itemRequest.Data); err != nil {
+			return nil, fmt.Errorf("invalid item: %w", err)
+		}
+	} else if err := json.Unmarshal(trimmedItem, &itemRequest); err != nil {
+		return nil, fmt.Errorf("invalid item: %w", err)
+	}
+
+	if len(itemRequest.Data) <= 0 {
+		return nil, errors.New("no data")
+	}
+
+	return &itemRequest, nil
+}
+
+// buildPngBatch captures every item concurrently, the tab pool limits how many render at once
+func buildPngBatch(batchRequest *PngBatchRequest, serverOptions *ServerOptions) ([]pngBatchCapture, error) {
+	if len(batchRequest.Items) == 0 {
+		return nil, errors.New("no items")
+	}
+
+	if len(batchRequest.Items) > maxBatchItems {
+		return nil, fmt.Errorf("a batch is limited to %d items", maxBatchItems)
+	}
+
+	results := make([]pngBatchCapture, len(batchRequest.Items))
+	itemRequests := make(chan int)
+
+	// There is no point in more workers than tabs, the others would only wait for one
+	var wg sync.WaitGroup
+	for range min(serverOptions.TabPool.Size(), len(batchRequest.Items)) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			for index := range itemRequests {
+				results[index] = buildPngBatchItem(&batchRequest.Defaults, batchRequest.Items[index], serverOptions)
+			}
+		}()
+	}
+
+	for index := range batchRequest.Items {
+		itemRequests <- index
+	}
+	close(itemRequests)
+	wg.Wait()
+
+	return results, nil
+}
+
+// buildPngBatchItem captures a single item. It runs outside of the request goroutine where gin can not recover a
+// panic, so a panic fails the item instead of the server.
+func buildPngBatchItem(defaults *PngRequest, item json.RawMessage, serverOptions *ServerOptions) (result pngBatchCapture) {
+	defer func() {
+		if recovered := recover(); recovered != nil {
+			log.Printf("Screenshot batch item panicked: %v", recovered)
+			result = pngBatchCapture{Err: fmt.Errorf("unable to capture item: %v", recovered)}
+		}
+	}()
+
+	itemRequest, err := getBatchItemRequest(defaults, item)
+	if err != nil {
+		return pngBatchCapture{Err: err}
+	}
+
+	captures, err := buildPng(itemRequest, serverOptions)
+	return pngBatchCapture{Format: itemRequest.Format, Captures: captures, Err: err}
+}
+
+// newPngBatchResponse lists the images or the error of every item
+func newPngBatchResponse(results []pngBatchCapture, url string) *PngBatchResponse {
+	response := &PngBatchResponse{Results: make([]PngBatchResult, len(results))}
+	for index, result := range results {
+		response.Results[index] = PngBatchResult{Index: index, Success: result.Err == nil, Status: http.StatusOK}
+		if result.Err != nil {
+			response.Results[index].Status = renderErrorStatus(result.Err)
+			response.Results[index].Error = result.Err.Error()
+			continue
+		}
+
+		response.Results[index].Result = newPngResponse(result.Captures, result.Format, url)
+	}
+
+	return response
+}
+
+// writePngBatchZip writes the images of every item as a zip to output, named by item number and viewport, and lists
+// the items that failed in errors.txt
+func writePngBatchZip(results []pngBatchCapture, output io.Writer) error {
+	archive := zip.NewWriter(output)
+
+	var failures bytes.Buffer
+	for index, result := range results {
+		if result.Err != nil {
+			fmt.Fprintf(&failures, "item %d: %s\n", index, result.Err.Error())
+			continue
+		}
+
+		// Several elements matching a selector are numbered within their viewport
+		viewportCaptures := make(map[string]int)
+		for _, capture := range result.Captures {
+			viewportCaptures[capture.Viewport]++
+
+			name := fmt.Sprintf("item-%03d", index)
+			if capture.Viewport != "" {
+				name += "-" + capture.Viewport
+			}
+			if viewportCaptures[capture.Viewport] > 1 {
+				name += fmt.Sprintf("-%d", viewportCaptures[capture.Viewport])
+			}
+
+			if err := addZipFile(archive, capture.File.Name(), name+filepath.Ext(capture.File.Name())); err != nil {
+				return err
+			}
+		}
+	}
+
+	if failures.Len() > 0 {
+		entry, err := archive.Create("errors.txt")
+		if err == nil {
+			_, err = entry.Write(failures.Bytes())
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	return archive.Close()
+}
+
+func addZipFile(archive *zip.Writer, fileName string, name string) error {
+	file, err := os.Open(fileName)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	entry, err := archive.Create(name)
+	if err != nil {
+		return err
+	}
+
+	_, err = io.Copy(entry, file)
+
+	return err
+}
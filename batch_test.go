@@ -0,0 +1,60 @@
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestBuildPngBatchItemRecovers(t *testing.T) {
+	// Without server options capturing panics, the item fails instead
+	result := buildPngBatchItem(&PngRequest{}, []byte(`"<p>Hello</p>"`), nil)
+	if result.Err == nil {
+		t.Error("expected the panic to fail the item")
+	}
+}
+
+func TestWritePngBatchZip(t *testing.T) {
+	directory := t.TempDir()
+
+	var captures []pngCapture
+	for _, name := range []string{"first.png", "second.png"} {
+		file, err := os.Create(filepath.Join(directory, name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		file.WriteString(name)
+		file.Close()
+
+		captures = append(captures, pngCapture{Viewport: "mobile", File: file})
+	}
+
+	results := []pngBatchCapture{
+		{Format: PreviewFormatPng, Captures: captures},
+		{Err: errors.New("no data")},
+	}
+
+	var output bytes.Buffer
+	if err := writePngBatchZip(results, &output); err != nil {
+		t.Fatal(err)
+	}
+
+	archive, err := zip.NewReader(bytes.NewReader(output.Bytes()), int64(output.Len()))
+	if err != nil {
+		t.Fatalf("the output is not a zip: %s", err)
+	}
+
+	var names []string
+	for _, entry := range archive.File {
+		names = append(names, entry.Name)
+	}
+
+	want := []string{"item-000-mobile.png", "item-000-mobile-2.png", "errors.txt"}
+	if !slices.Equal(names, want) {
+		t.Errorf("zip holds %v, want %v", names, want)
+	}
+}
@@ -20,11 +20,16 @@ func ClearFileMiddleware(rootDirectory *string) gin.HandlerFunc {
 			u := c.Request.URL
 			u.RawQuery = ""
 
-			if strings.HasPrefix(u.Path, "/preview/") || strings.HasPrefix(u.Path, "/png/") || strings.HasPrefix(u.Path, "/pdfs/") {
+			if strings.HasPrefix(u.Path, "/preview/") || strings.HasPrefix(u.Path, "/png/") || strings.HasPrefix(u.Path, "/pdfs/") || strings.HasPrefix(u.Path, "/archives/") {
 				filePath := *rootDirectory + "/files" + u.Path
 				log.Println(filePath)
 				os.Remove(filePath)
 
+				// The page images rendered for a document go with it
+				if strings.HasPrefix(u.Path, "/pdfs/") {
+					removePageImages(filePath, *rootDirectory+"/files/"+DirectoryKeyPreview)
+				}
+
 				return
 			}
 		}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+
+	"github.com/chromedp/cdproto/emulation"
+	"github.com/chromedp/cdproto/page"
+	"github.com/chromedp/cdproto/runtime"
+	"github.com/chromedp/chromedp"
+)
+
+const (
+	// cssPixelsPerInch is the resolution Chrome lays out printed pages at
+	cssPixelsPerInch = 96
+	// defaultPaperWidth is the width Chrome prints at without a paper size, US letter
+	defaultPaperWidth = 8.5
+	// maxPaperHeight is the 14400 point page size limit of pdf readers
+	maxPaperHeight = 200
+	// measureViewportHeight is the window height the document is laid out in before it is measured
+	measureViewportHeight = 1000
+)
+
+// contentHeightScript returns the height of the document in css pixels
+const contentHeightScript = `Math.max(document.documentElement.scrollHeight, document.body ? document.body.scrollHeight : 0)`
+
+// getContinuousPrintOptions returns a copy of params with the paper as tall as the printed document. Documents taller
+// than pdf readers allow are split into pages of equal height.
+func getContinuousPrintOptions(ctx context.Context, params *page.PrintToPDFParams) (*page.PrintToPDFParams, error) {
+	continuousParams := *params
+	if continuousParams.PaperWidth == 0 {
+		continuousParams.PaperWidth = defaultPaperWidth
+	}
+
+	contentWidth := (continuousParams.PaperWidth - continuousParams.MarginLeft - continuousParams.MarginRight) * cssPixelsPerInch
+	if contentWidth < 1 {
+		return nil, errors.New("the margins leave no room for content on a continuous page")
+	}
+
+	// The document is laid out as it will be printed, at the width of the printed content
+	var contentHeight float64
+	err := chromedp.Run(ctx,
+		emulation.SetEmulatedMedia().WithMedia("print"),
+		emulation.SetDeviceMetricsOverride(int64(math.Floor(contentWidth)), measureViewportHeight, 1, false),
+		chromedp.Evaluate(settleViewportScript, nil, func(p *runtime.EvaluateParams) *runtime.EvaluateParams {
+			return p.WithAwaitPromise(true)
+		}),
+		chromedp.Evaluate(contentHeightScript, &contentHeight),
+		emulation.ClearDeviceMetricsOverride(),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("unable to measure the document: %w", err)
+	}
+
+	// A pixel of slack keeps rounding from pushing the last line onto another page
+	contentHeightInches := (math.Ceil(contentHeight) + 1) / cssPixelsPerInch
+	margins := continuousParams.MarginTop + continuousParams.MarginBottom
+	if margins >= maxPaperHeight {
+		return nil, errors.New("the margins leave no room for content on a continuous page")
+	}
+
+	pages := math.Ceil(contentHeightInches / (maxPaperHeight - margins))
+	continuousParams.PaperHeight = contentHeightInches/pages + margins
+	continuousParams.PreferCSSPageSize = false
+
+	return &continuousParams, nil
+}
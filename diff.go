@@ -0,0 +1,656 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	_ "image/jpeg"
+	"image/png"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/gin-contrib/location"
+	"github.com/gin-gonic/gin"
+	_ "golang.org/x/image/webp"
+)
+
+const (
+	// maxDiffPixels limits the size of every compared image and pdf page
+	maxDiffPixels = 50_000_000
+	// maxDiffPdfPixels limits the size of all the pages of a compared pdf together
+	maxDiffPdfPixels = 500_000_000
+	// maxDiffPdfPages limits the pages of a compared pdf
+	maxDiffPdfPages = 200
+)
+
+// diffFadeRate is how much of the contrast unchanged pixels keep in diff images
+const diffFadeRate = 0.15
+
+var (
+	diffChangedColor     = color.NRGBA{R: 255, A: 255}
+	diffAntiAliasedColor = color.NRGBA{R: 255, G: 200, A: 255}
+	diffIgnoredColor     = color.NRGBA{R: 200, G: 220, B: 255, A: 255}
+)
+
+// DiffSource is an image or pdf to compare, either a file in the pdfs or png directory, or base64 content, a data uri
+// or a url
+type DiffSource struct {
+	File string `json:"file"`
+	Data string `json:"data"`
+}
+
+// DiffRegion is an area to leave out of the comparison, in pixels of the compared images. A Page of 0 applies to
+// every page.
+type DiffRegion struct {
+	Page   int `json:"page"`
+	X      int `json:"x"`
+	Y      int `json:"y"`
+	Width  int `json:"width"`
+	Height int `json:"height"`
+}
+
+// DiffRequest compares expected with actual, or with the rendering of a screenshot or pdf request. Form submissions
+// provide the objects as json strings and may upload the "expected" and "actual" files instead.
+type DiffRequest struct {
+	Expected   *DiffSource `json:"expected" form:"-"`
+	Actual     *DiffSource `json:"actual" form:"-"`
+	Screenshot *PngRequest `json:"screenshot" form:"-"`
+	Pdf        *PdfRequest `json:"pdf" form:"-"`
+	// Dpi is the resolution pdf pages are rendered at, without it the longest side of a page is 1024 pixels
+	Dpi int `json:"dpi" form:"dpi"`
+	// Threshold is the largest mismatch percentage a page may have and still pass
+	Threshold float64 `json:"threshold" form:"threshold"`
+	// Tolerance is the largest difference of a color channel, 0-255, that still counts as the same color
+	Tolerance int `json:"tolerance" form:"tolerance"`
+	// AntiAliasing ignores changed pixels that match a neighbouring pixel in the other image both ways
+	AntiAliasing  bool         `json:"antiAliasing" form:"antiAliasing"`
+	IgnoreRegions []DiffRegion `json:"ignoreRegions" form:"-"`
+}
+
+type DiffResponse struct {
+	Passed    bool       `json:"passed"`
+	Threshold float64    `json:"threshold"`
+	Pages     []DiffPage `json:"pages"`
+}
+
+// DiffPage is the comparison of a page, Mismatch is the percentage of the compared pixels that changed
+type DiffPage struct {
+	Page          int     `json:"page"`
+	Width         int     `json:"width"`
+	Height        int     `json:"height"`
+	ChangedPixels int     `json:"changedPixels"`
+	Mismatch      float64 `json:"mismatch"`
+	Passed        bool    `json:"passed"`
+	Diff          string  `json:"diff,omitempty"`
+	Error         string  `json:"error,omitempty"`
+}
+
+// diffPageResult is a compared page and the name of its diff image
+type diffPageResult struct {
+	DiffPage
+	DiffFile string
+}
+
+// getDiffRequestForm reads the objects of a form submission from their json strings
+func getDiffRequestForm(c *gin.Context, diffRequest *DiffRequest) error {
+	fields := map[string]any{
+		"expected":      &diffRequest.Expected,
+		"actual":        &diffRequest.Actual,
+		"screenshot":    &diffRequest.Screenshot,
+		"pdf":           &diffRequest.Pdf,
+		"ignoreRegions": &diffRequest.IgnoreRegions,
+	}
+
+	for name, field := range fields {
+		if value := c.PostForm(name); value != "" {
+			if err := json.Unmarshal([]byte(value), field); err != nil {
+				return fmt.Errorf("invalid %s: %w", name, err)
+			}
+		}
+	}
+
+	// Uploads stand in for the sources
+	if _, err := c.FormFile("expected"); err == nil && diffRequest.Expected == nil {
+		diffRequest.Expected = &DiffSource{}
+	}
+
+	if _, err := c.FormFile("actual"); err == nil && diffRequest.Actual == nil {
+		diffRequest.Actual = &DiffSource{}
+	}
+
+	return nil
+}
+
+// buildDiff compares the expected and actual pages of a request
+func buildDiff(c *gin.Context, diffRequest *DiffRequest, options *ServerOptions) (*DiffResponse, error) {
+	expectedData, err := readDiffSource(c, "expected", diffRequest.Expected, options)
+	if err != nil {
+		return nil, err
+	}
+
+	expected, err := newDiffDocument([][]byte{expectedData}, diffRequest.Dpi, options)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read expected: %w", err)
+	}
+	defer expected.Close()
+
+	var actualData [][]byte
+	if diffRequest.Actual != nil {
+		data, err := readDiffSource(c, "actual", diffRequest.Actual, options)
+		if err != nil {
+			return nil, err
+		}
+		actualData = [][]byte{data}
+	} else {
+		actualData, err = renderDiffActual(diffRequest, options)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	actual, err := newDiffDocument(actualData, diffRequest.Dpi, options)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read actual: %w", err)
+	}
+	defer actual.Close()
+
+	results, err := compareDiffPages(expected, actual, diffRequest, options)
+	if err != nil {
+		return nil, err
+	}
+
+	serverUrl := location.Get(c)
+	url := serverUrl.Scheme + "://" + serverUrl.Host + "/png/"
+
+	response := &DiffResponse{Passed: true, Threshold: diffRequest.Threshold, Pages: make([]DiffPage, len(results))}
+	for index, result := range results {
+		response.Pages[index] = result.DiffPage
+		if result.DiffFile != "" {
+			response.Pages[index].Diff = url + filepath.Base(result.DiffFile)
+		}
+
+		response.Passed = response.Passed && result.Passed
+	}
+
+	return response, nil
+}
+
+func validateDiffRequest(diffRequest *DiffRequest) error {
+	if diffRequest.Expected == nil {
+		return errors.New("expected is required")
+	}
+
+	renderers := 0
+	for _, set := range []bool{diffRequest.Actual != nil, diffRequest.Screenshot != nil, diffRequest.Pdf != nil} {
+		if set {
+			renderers++
+		}
+	}
+	if renderers != 1 {
+		return errors.New("set one of actual, screenshot or pdf")
+	}
+
+	if diffRequest.Dpi < 0 || diffRequest.Dpi > maxPageImageDpi {
+		return fmt.Errorf("dpi must be between 1 and %d", maxPageImageDpi)
+	}
+
+	if diffRequest.Threshold < 0 || diffRequest.Threshold > 100 {
+		return errors.New("threshold must be a percentage between 0 and 100")
+	}
+
+	if diffRequest.Tolerance < 0 || diffRequest.Tolerance > 255 {
+		return errors.New("tolerance must be between 0 and 255")
+	}
+
+	for _, region := range diffRequest.IgnoreRegions {
+		if region.Page < 0 || region.X < 0 || region.Y < 0 || region.Width <= 0 || region.Height <= 0 {
+			return fmt.Errorf("invalid ignore region %+v, the size must be positive", region)
+		}
+	}
+
+	return nil
+}
+
+// readDiffSource returns the content of the upload called name or of source
+func readDiffSource(c *gin.Context, name string, source *DiffSource, options *ServerOptions) ([]byte, error) {
+	if upload, err := c.FormFile(name); err == nil {
+		file, err := upload.Open()
+		if err != nil {
+			return nil, fmt.Errorf("unable to read uploaded %s: %w", name, err)
+		}
+		defer file.Close()
+
+		data, err := readLimited(file, options.MaxSourceSize)
+		if err != nil {
+			return nil, fmt.Errorf("unable to read uploaded %s: %w", name, err)
+		}
+
+		return data, nil
+	}
+
+	if source == nil {
+		return nil, fmt.Errorf("%s is required", name)
+	}
+
+	if source.File != "" {
+		// Only pdfs in the pdfs directory and images in the png directory may be used
+		directory := *options.DirectoryMap[DirectoryKeyPng]
+		if strings.EqualFold(filepath.Ext(source.File), ".pdf") {
+			directory = *options.DirectoryMap[DirectoryKeyPdf]
+		}
+
+		data, err := os.ReadFile(filepath.Join(directory, filepath.Base(source.File)))
+		if err != nil {
+			return nil, fmt.Errorf("unable to read %s", filepath.Base(source.File))
+		}
+
+		return data, nil
+	}
+
+	trimmed := strings.TrimSpace(source.Data)
+	switch {
+	case trimmed == "":
+		return nil, fmt.Errorf("one of file, data or an upload is required for %s", name)
+	case dataUriPattern.MatchString(trimmed):
+		_, data, err := decodeDataUri(trimmed)
+		return data, err
+	case httpUrlPattern.MatchString(trimmed):
+		if !options.FetchUrls {
+			return nil, ErrFetchUrlsDisabled
+		}
+
+		return fetchUrl(trimmed, options.MaxSourceSize)
+	default:
+		data, err := decodeBase64(trimmed)
+		if err != nil {
+			return nil, fmt.Errorf("unable to decode %s: %w", name, err)
+		}
+
+		return data, nil
+	}
+}
+
+// renderDiffActual renders the screenshot or pdf request of a diff and returns the content of the result
+func renderDiffActual(diffRequest *DiffRequest, options *ServerOptions) ([][]byte, error) {
+	if diffRequest.Pdf != nil {
+		pdfResult, err := buildPdf(diffRequest.Pdf, options)
+		if err != nil {
+			return nil, fmt.Errorf("unable to render pdf: %w", err)
+		}
+
+		// Nothing links to the rendered pdfs so nothing would clear them
+		defer func() {
+			os.Remove(pdfResult.OutputFile.Name())
+			for _, outputFile := range pdfResult.OutputFiles {
+				os.Remove(outputFile)
+			}
+		}()
+
+		pdfData, err := os.ReadFile(pdfResult.OutputFile.Name())
+		if err != nil {
+			return nil, err
+		}
+
+		return [][]byte{pdfData}, nil
+	}
+
+	// The images of every viewport and selected element are compared in order
+	captures, err := buildPng(diffRequest.Screenshot, options)
+	if err != nil {
+		return nil, err
+	}
+
+	defer func() {
+		for _, capture := range captures {
+			os.Remove(capture.File.Name())
+		}
+	}()
+
+	var images [][]byte
+	for _, capture := range captures {
+		imageData, err := os.ReadFile(capture.File.Name())
+		if err != nil {
+			return nil, err
+		}
+		images = append(images, imageData)
+	}
+
+	return images, nil
+}
+
+// diffDocument is a compared pdf, or the compared images in order. Pages are only decoded, and pdf pages only
+// rendered, when they are compared so that a single page of each document is held in memory at a time.
+type diffDocument struct {
+	images  [][]byte
+	pdfFile string
+	pages   int
+	dpi     int
+	options *ServerOptions
+}
+
+// newDiffDocument checks the pdf or the images of data, a pdf has to be compared on its own
+func newDiffDocument(data [][]byte, dpi int, options *ServerOptions) (*diffDocument, error) {
+	document := &diffDocument{dpi: dpi, options: options}
+	if len(data) == 1 && bytes.HasPrefix(data[0], []byte(pdfPrefix)) {
+		if err := document.openPdf(data[0]); err != nil {
+			return nil, err
+		}
+
+		return document, nil
+	}
+
+	for _, imageData := range data {
+		if _, err := checkDiffImage(bytes.NewReader(imageData)); err != nil {
+			return nil, err
+		}
+	}
+
+	document.images = data
+	document.pages = len(data)
+
+	return document, nil
+}
+
+// openPdf stores the pdf for its pages to be rendered, after checking the size they will be rendered at
+func (document *diffDocument) openPdf(data []byte) error {
+	if document.options.Rasterizer == nil {
+		return errors.New("unable to compare pdfs: no rasterizer is available, install poppler-utils")
+	}
+
+	if err := document.options.PdfEngine.Validate(data); err != nil {
+		return fmt.Errorf("invalid pdf: %w", err)
+	}
+
+	pdfFile, err := writePdfFile(data, "*-diff.pdf", document.options)
+	if err != nil {
+		return err
+	}
+
+	pdfInfo, err := getPdfInfo(pdfFile, document.options)
+	if err != nil {
+		os.Remove(pdfFile)
+		return err
+	}
+
+	if pdfInfo.Pages > maxDiffPdfPages {
+		os.Remove(pdfFile)
+		return fmt.Errorf("the pdf has more than %d pages", maxDiffPdfPages)
+	}
+
+	size := PdfPreviewSize{Dpi: document.dpi}
+	totalPixels := 0.0
+	for _, pageSize := range pdfInfo.PageSizes {
+		scale := size.scale(pageSize.Width, pageSize.Height)
+		pixels := pageSize.Width * scale * pageSize.Height * scale
+		if pixels > maxDiffPixels {
+			os.Remove(pdfFile)
+			return fmt.Errorf("page %d is larger than %d pixels at %d dpi", pageSize.Page, maxDiffPixels, document.dpi)
+		}
+
+		totalPixels += pixels
+	}
+
+	if totalPixels > maxDiffPdfPixels {
+		os.Remove(pdfFile)
+		return fmt.Errorf("the pages of the pdf are larger than %d pixels together", maxDiffPdfPixels)
+	}
+
+	document.pdfFile = pdfFile
+	document.pages = pdfInfo.Pages
+
+	return nil
+}
+
+// page decodes the page numbered from 1, rendering it first for a pdf
+func (document *diffDocument) page(pageNumber int) (image.Image, error) {
+	if document.pdfFile == "" {
+		return decodeDiffImage(bytes.NewReader(document.images[pageNumber-1]))
+	}
+
+	outputPrefix := filepath.Join(*document.options.DirectoryMap[DirectoryKeyPreview], fileNameWithoutExtension(filepath.Base(document.pdfFile)))
+	pageImages, err := document.options.Rasterizer.Rasterize(document.pdfFile, outputPrefix, RasterOptions{Format: PreviewFormatPng, Dpi: document.dpi, FirstPage: pageNumber, LastPage: pageNumber})
+	defer func() {
+		for _, pageImage := range pageImages {
+			os.Remove(pageImage)
+		}
+	}()
+	if err != nil {
+		return nil, fmt.Errorf("unable to render page %d: %w", pageNumber, err)
+	}
+
+	pageImage, ok := pageImages[pageNumber]
+	if !ok {
+		return nil, fmt.Errorf("unable to render page %d", pageNumber)
+	}
+
+	file, err := os.Open(pageImage)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	decoded, err := decodeDiffImage(file)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read page %d: %w", pageNumber, err)
+	}
+
+	return decoded, nil
+}
+
+// Close removes the stored pdf
+func (document *diffDocument) Close() {
+	if document.pdfFile != "" {
+		os.Remove(document.pdfFile)
+	}
+}
+
+// checkDiffImage reads the size of a png, jpeg or webp image and checks that it can be compared
+func checkDiffImage(reader io.Reader) (image.Config, error) {
+	config, _, err := image.DecodeConfig(reader)
+	if err != nil {
+		return config, fmt.Errorf("not a pdf or a png, jpeg or webp image: %w", err)
+	}
+
+	if config.Width*config.Height > maxDiffPixels {
+		return config, fmt.Errorf("the image is larger than %d pixels", maxDiffPixels)
+	}
+
+	return config, nil
+}
+
+// decodeDiffImage decodes an image once its size is checked
+func decodeDiffImage(reader io.ReadSeeker) (image.Image, error) {
+	if _, err := checkDiffImage(reader); err != nil {
+		return nil, err
+	}
+
+	if _, err := reader.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+
+	decoded, _, err := image.Decode(reader)
+
+	return decoded, err
+}
+
+// compareDiffPages compares the pages of expected and actual in order, one pair at a time, and writes a diff image
+// per page
+func compareDiffPages(expected *diffDocument, actual *diffDocument, diffRequest *DiffRequest, options *ServerOptions) ([]diffPageResult, error) {
+	pageCount := max(expected.pages, actual.pages)
+
+	results := make([]diffPageResult, pageCount)
+	for index := range pageCount {
+		pageNumber := index + 1
+		results[index].Page = pageNumber
+
+		if pageNumber > expected.pages || pageNumber > actual.pages {
+			missing := "expected"
+			if pageNumber > actual.pages {
+				missing = "actual"
+			}
+
+			results[index].Mismatch = 100
+			results[index].Error = fmt.Sprintf("page %d is missing from %s", pageNumber, missing)
+			continue
+		}
+
+		expectedPage, err := expected.page(pageNumber)
+		if err != nil {
+			return nil, fmt.Errorf("unable to read expected: %w", err)
+		}
+
+		actualPage, err := actual.page(pageNumber)
+		if err != nil {
+			return nil, fmt.Errorf("unable to read actual: %w", err)
+		}
+
+		var regions []image.Rectangle
+		for _, region := range diffRequest.IgnoreRegions {
+			if region.Page == 0 || region.Page == pageNumber {
+				regions = append(regions, image.Rect(region.X, region.Y, region.X+region.Width, region.Y+region.Height))
+			}
+		}
+
+		diffImage, changed, compared := diffImages(expectedPage, actualPage, diffRequest.Tolerance, diffRequest.AntiAliasing, regions)
+
+		results[index].Width = diffImage.Bounds().Dx()
+		results[index].Height = diffImage.Bounds().Dy()
+		results[index].ChangedPixels = changed
+		if compared > 0 {
+			results[index].Mismatch = math.Round(float64(changed)/float64(compared)*10000) / 100
+		}
+		results[index].Passed = results[index].Mismatch <= diffRequest.Threshold
+
+		diffFile, err := os.CreateTemp(*options.DirectoryMap[DirectoryKeyPng], "*-diff.png")
+		if err != nil {
+			return nil, errors.New("unable to create output file")
+		}
+
+		err = png.Encode(diffFile, diffImage)
+		diffFile.Close()
+		if err != nil {
+			return nil, fmt.Errorf("unable to write diff image: %w", err)
+		}
+
+		results[index].DiffFile = diffFile.Name()
+	}
+
+	return results, nil
+}
+
+// diffImages highlights the pixels of actual that differ from expected on a faded copy of expected and counts them.
+// Images of different sizes are compared over the larger size, pixels only one of them covers count as changed.
+func diffImages(expected image.Image, actual image.Image, tolerance int, antiAliasing bool, ignoreRegions []image.Rectangle) (*image.NRGBA, int, int) {
+	expectedBounds := expected.Bounds()
+	actualBounds := actual.Bounds()
+	width := max(expectedBounds.Dx(), actualBounds.Dx())
+	height := max(expectedBounds.Dy(), actualBounds.Dy())
+
+	expectedPixels := newPixelReader(expected)
+	actualPixels := newPixelReader(actual)
+
+	diffImage := image.NewNRGBA(image.Rect(0, 0, width, height))
+	changed := 0
+	compared := 0
+
+	for y := range height {
+		for x := range width {
+			point := image.Pt(x, y)
+			if slices.ContainsFunc(ignoreRegions, point.In) {
+				diffImage.SetNRGBA(x, y, diffIgnoredColor)
+				continue
+			}
+			compared++
+
+			expectedColor, inExpected := expectedPixels.at(x, y)
+			actualColor, inActual := actualPixels.at(x, y)
+			if !inExpected || !inActual {
+				diffImage.SetNRGBA(x, y, diffChangedColor)
+				changed++
+				continue
+			}
+
+			if colorsMatch(expectedColor, actualColor, tolerance) {
+				diffImage.SetNRGBA(x, y, fadeColor(expectedColor))
+				continue
+			}
+
+			if antiAliasing && hasMatchingNeighbour(expectedColor, actualPixels, x, y, tolerance) && hasMatchingNeighbour(actualColor, expectedPixels, x, y, tolerance) {
+				diffImage.SetNRGBA(x, y, diffAntiAliasedColor)
+				continue
+			}
+
+			diffImage.SetNRGBA(x, y, diffChangedColor)
+			changed++
+		}
+	}
+
+	return diffImage, changed, compared
+}
+
+// pixelReader reads the pixels of an image relative to its top left corner
+type pixelReader struct {
+	image  image.Image
+	bounds image.Rectangle
+}
+
+func newPixelReader(source image.Image) pixelReader {
+	return pixelReader{image: source, bounds: source.Bounds()}
+}
+
+func (reader pixelReader) at(x int, y int) (color.NRGBA, bool) {
+	if x < 0 || y < 0 || x >= reader.bounds.Dx() || y >= reader.bounds.Dy() {
+		return color.NRGBA{}, false
+	}
+
+	return color.NRGBAModel.Convert(reader.image.At(reader.bounds.Min.X+x, reader.bounds.Min.Y+y)).(color.NRGBA), true
+}
+
+func colorsMatch(first color.NRGBA, second color.NRGBA, tolerance int) bool {
+	return channelDistance(first.R, second.R) <= tolerance &&
+		channelDistance(first.G, second.G) <= tolerance &&
+		channelDistance(first.B, second.B) <= tolerance &&
+		channelDistance(first.A, second.A) <= tolerance
+}
+
+func channelDistance(first uint8, second uint8) int {
+	if first > second {
+		return int(first - second)
+	}
+
+	return int(second - first)
+}
+
+// hasMatchingNeighbour reports whether pixel matches one of the pixels around x, y in other, anti-aliased edges
+// shift by about a pixel between renderings
+func hasMatchingNeighbour(pixel color.NRGBA, other pixelReader, x int, y int, tolerance int) bool {
+	for offsetY := -1; offsetY <= 1; offsetY++ {
+		for offsetX := -1; offsetX <= 1; offsetX++ {
+			if offsetX == 0 && offsetY == 0 {
+				continue
+			}
+
+			if neighbour, found := other.at(x+offsetX, y+offsetY); found && colorsMatch(pixel, neighbour, tolerance) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// fadeColor turns an unchanged pixel into a light gray so the changes stand out
+func fadeColor(pixel color.NRGBA) color.NRGBA {
+	// Transparent pixels are shown on white
+	gray := (0.299*float64(pixel.R)+0.587*float64(pixel.G)+0.114*float64(pixel.B))*float64(pixel.A)/255 + float64(255-pixel.A)
+	faded := uint8(255 - (255-gray)*diffFadeRate)
+
+	return color.NRGBA{R: faded, G: faded, B: faded, A: 255}
+}
@@ -0,0 +1,284 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"hash/crc32"
+	"image"
+	"image/color"
+	"image/png"
+	"os"
+	"testing"
+)
+
+// newTestImage returns a white image of width by height pixels with the points set to black
+func newTestImage(width int, height int, points ...image.Point) *image.NRGBA {
+	img := image.NewNRGBA(image.Rect(0, 0, width, height))
+	for y := range height {
+		for x := range width {
+			img.SetNRGBA(x, y, color.NRGBA{R: 255, G: 255, B: 255, A: 255})
+		}
+	}
+
+	for _, point := range points {
+		img.SetNRGBA(point.X, point.Y, color.NRGBA{A: 255})
+	}
+
+	return img
+}
+
+func TestDiffImages(t *testing.T) {
+	tests := []struct {
+		name          string
+		expected      image.Image
+		actual        image.Image
+		tolerance     int
+		antiAliasing  bool
+		ignoreRegions []image.Rectangle
+		changed       int
+		compared      int
+	}{
+		{
+			name:     "identical",
+			expected: newTestImage(4, 4, image.Pt(1, 1)),
+			actual:   newTestImage(4, 4, image.Pt(1, 1)),
+			compared: 16,
+		},
+		{
+			name:     "changed pixel",
+			expected: newTestImage(4, 4),
+			actual:   newTestImage(4, 4, image.Pt(2, 3)),
+			changed:  1,
+			compared: 16,
+		},
+		{
+			name:      "within tolerance",
+			expected:  newTestImage(2, 2),
+			actual:    newUniformTestImage(image.Rect(0, 0, 2, 2), color.NRGBA{R: 250, G: 250, B: 250, A: 255}),
+			tolerance: 5,
+			compared:  4,
+		},
+		{
+			name:         "anti-aliased edge moved by a pixel",
+			expected:     newTestImage(4, 4, image.Pt(1, 1)),
+			actual:       newTestImage(4, 4, image.Pt(2, 1)),
+			antiAliasing: true,
+			compared:     16,
+		},
+		{
+			name:     "edge moved without anti-aliasing",
+			expected: newTestImage(4, 4, image.Pt(1, 1)),
+			actual:   newTestImage(4, 4, image.Pt(2, 1)),
+			changed:  2,
+			compared: 16,
+		},
+		{
+			name:          "ignored region",
+			expected:      newTestImage(4, 4),
+			actual:        newTestImage(4, 4, image.Pt(0, 0), image.Pt(3, 3)),
+			ignoreRegions: []image.Rectangle{image.Rect(0, 0, 2, 2)},
+			changed:       1,
+			compared:      12,
+		},
+		{
+			name:     "different sizes",
+			expected: newTestImage(4, 4),
+			actual:   newTestImage(4, 2),
+			changed:  8,
+			compared: 16,
+		},
+		{
+			name:     "offset bounds",
+			expected: newTestImage(4, 4, image.Pt(1, 1)),
+			actual:   newTestImage(6, 6, image.Pt(3, 3)).SubImage(image.Rect(2, 2, 6, 6)),
+			compared: 16,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			diffImage, changed, compared := diffImages(test.expected, test.actual, test.tolerance, test.antiAliasing, test.ignoreRegions)
+			if changed != test.changed || compared != test.compared {
+				t.Errorf("changed %d of %d pixels, want %d of %d", changed, compared, test.changed, test.compared)
+			}
+
+			// The diff covers both images
+			width := max(test.expected.Bounds().Dx(), test.actual.Bounds().Dx())
+			height := max(test.expected.Bounds().Dy(), test.actual.Bounds().Dy())
+			if diffImage.Bounds() != image.Rect(0, 0, width, height) {
+				t.Errorf("diff image is %v, want %dx%d", diffImage.Bounds(), width, height)
+			}
+		})
+	}
+}
+
+func TestDiffImagesColors(t *testing.T) {
+	expected := newTestImage(4, 4, image.Pt(1, 1))
+	actual := newTestImage(4, 4, image.Pt(2, 1), image.Pt(3, 3))
+
+	diffImage, _, _ := diffImages(expected, actual, 0, true, []image.Rectangle{image.Rect(0, 3, 1, 4)})
+
+	tests := map[image.Point]color.NRGBA{
+		image.Pt(1, 1): diffAntiAliasedColor,
+		image.Pt(3, 3): diffChangedColor,
+		image.Pt(0, 3): diffIgnoredColor,
+		image.Pt(0, 0): fadeColor(color.NRGBA{R: 255, G: 255, B: 255, A: 255}),
+	}
+
+	for point, want := range tests {
+		if got := diffImage.NRGBAAt(point.X, point.Y); got != want {
+			t.Errorf("pixel %v is %v, want %v", point, got, want)
+		}
+	}
+}
+
+func TestCompareDiffPages(t *testing.T) {
+	directory := t.TempDir()
+	options := &ServerOptions{DirectoryMap: map[string]*string{DirectoryKeyPng: &directory}}
+
+	expected := newTestDiffDocument(t, newTestImage(10, 10), newTestImage(10, 10))
+	actual := newTestDiffDocument(t, newTestImage(10, 10, image.Pt(5, 5)))
+
+	results, err := compareDiffPages(expected, actual, &DiffRequest{Threshold: 0.5}, options)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(results) != 2 {
+		t.Fatalf("%d pages compared, want 2", len(results))
+	}
+
+	if results[0].Mismatch != 1 || results[0].Passed || results[0].ChangedPixels != 1 {
+		t.Errorf("page 1 mismatch %v%% passed %t, want 1%% failed", results[0].Mismatch, results[0].Passed)
+	}
+
+	if results[1].Error == "" || results[1].Mismatch != 100 || results[1].Passed {
+		t.Errorf("page 2 is missing from actual but reported %+v", results[1])
+	}
+
+	diffFile, err := os.Open(results[0].DiffFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer diffFile.Close()
+
+	diffImage, err := png.Decode(diffFile)
+	if err != nil {
+		t.Fatalf("the diff image is not a png: %s", err)
+	}
+
+	if color.NRGBAModel.Convert(diffImage.At(5, 5)) != diffChangedColor {
+		t.Error("the changed pixel is not highlighted")
+	}
+}
+
+func TestNewDiffDocument(t *testing.T) {
+	document := newTestDiffDocument(t, newTestImage(3, 2), newTestImage(4, 4))
+	if document.pages != 2 {
+		t.Fatalf("%d pages, want 2", document.pages)
+	}
+
+	page, err := document.page(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if page.Bounds().Dx() != 3 || page.Bounds().Dy() != 2 {
+		t.Errorf("page 1 is %v, want the 3x2 image", page.Bounds())
+	}
+
+	tests := map[string][]byte{
+		"not an image":           []byte("<html></html>"),
+		"pdf without rasterizer": newTestPdf(t, 1),
+		"image too large":        newTestPngHeader(10_000, 10_000),
+	}
+
+	for name, data := range tests {
+		t.Run(name, func(t *testing.T) {
+			if _, err := newDiffDocument([][]byte{data}, 0, &ServerOptions{}); err == nil {
+				t.Error("expected an error")
+			}
+		})
+	}
+}
+
+func TestNewDiffDocumentPdfLimits(t *testing.T) {
+	directory := t.TempDir()
+	options := &ServerOptions{
+		DirectoryMap: map[string]*string{DirectoryKeyPdf: &directory},
+		PdfEngine:    NewPdfcpuEngine(),
+		// The pdf is checked before any page is rendered
+		Rasterizer: &PopplerRasterizer{},
+	}
+
+	document, err := newDiffDocument([][]byte{newTestPdf(t, 2)}, 72, options)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer document.Close()
+
+	if document.pages != 2 {
+		t.Errorf("%d pages, want 2", document.pages)
+	}
+
+	// An A4 page at 600 dpi is about 35 megapixels, 20 of them are over the limit together
+	if _, err := newDiffDocument([][]byte{newTestPdf(t, 20)}, 600, options); err == nil {
+		t.Error("expected an error for the total size of the pages")
+	}
+
+	if _, err := newDiffDocument([][]byte{newTestPdf(t, maxDiffPdfPages+1)}, 0, options); err == nil {
+		t.Error("expected an error for the page count")
+	}
+
+	if entries, _ := os.ReadDir(directory); len(entries) != 1 {
+		t.Errorf("%d pdfs stored, want only the open document", len(entries))
+	}
+}
+
+// newTestDiffDocument returns a document of the images encoded as png
+func newTestDiffDocument(t *testing.T, images ...image.Image) *diffDocument {
+	t.Helper()
+
+	var data [][]byte
+	for _, img := range images {
+		var encoded bytes.Buffer
+		if err := png.Encode(&encoded, img); err != nil {
+			t.Fatal(err)
+		}
+		data = append(data, encoded.Bytes())
+	}
+
+	document, err := newDiffDocument(data, 0, &ServerOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return document
+}
+
+// newTestPngHeader returns the signature and header chunk of a png of width by height pixels, enough to read its size
+func newTestPngHeader(width int, height int) []byte {
+	chunk := []byte("IHDR")
+	chunk = binary.BigEndian.AppendUint32(chunk, uint32(width))
+	chunk = binary.BigEndian.AppendUint32(chunk, uint32(height))
+	// 8 bit grayscale, without interlacing
+	chunk = append(chunk, 8, 0, 0, 0, 0)
+
+	header := []byte("\x89PNG\r\n\x1a\n")
+	header = binary.BigEndian.AppendUint32(header, uint32(len(chunk)-4))
+	header = append(header, chunk...)
+
+	return binary.BigEndian.AppendUint32(header, crc32.ChecksumIEEE(chunk))
+}
+
+// newUniformTestImage returns an image of bounds filled with c
+func newUniformTestImage(bounds image.Rectangle, c color.Color) *image.NRGBA {
+	img := image.NewNRGBA(bounds)
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			img.Set(x, y, c)
+		}
+	}
+
+	return img
+}
@@ -15,9 +15,9 @@ const docTemplate = `{
     "host": "{{.Host}}",
     "basePath": "{{.BasePath}}",
     "paths": {
-        "/pdf": {
+        "/archive": {
             "post": {
-                "description": "Submit urls/data to be converted to a PDF",
+                "description": "Archive a page as Chrome rendered it, as mhtml with every resource or as the html of the document after its scripts ran",
                 "consumes": [
                     "application/json",
                     "text/xml"
@@ -25,15 +25,15 @@ const docTemplate = `{
                 "produces": [
                     "application/json"
                 ],
-                "summary": "Submit urls/data to be converted to a PDF",
+                "summary": "Submit a single url or data to be archived",
                 "parameters": [
                     {
-                        "description": "The input todo struct",
+                        "description": "The input request",
                         "name": "data",
                         "in": "body",
                         "required": true,
                         "schema": {
-                            "$ref": "#/definitions/main.PdfRequest"
+                            "$ref": "#/definitions/main.ArchiveRequest"
                         }
                     }
                 ],
@@ -41,7 +41,7 @@ const docTemplate = `{
                     "200": {
                         "description": "OK",
                         "schema": {
-                            "$ref": "#/definitions/main.PdfResponse"
+                            "$ref": "#/definitions/main.ArchiveResponse"
                         }
                     },
                     "400": {
@@ -53,17 +53,17 @@ const docTemplate = `{
                 }
             }
         },
-        "/png": {
+        "/diff": {
             "post": {
-                "description": "Submit a single url or data to be converted to a png",
+                "description": "Compare an expected image or pdf with an actual one, or with the rendering of a screenshot or pdf request, and highlight the changed pixels of every page",
                 "consumes": [
                     "application/json",
-                    "text/xml"
+                    "multipart/form-data"
                 ],
                 "produces": [
                     "application/json"
                 ],
-                "summary": "Submit a single url or data to be converted to a png",
+                "summary": "Compare images or pdfs page by page",
                 "parameters": [
                     {
                         "description": "The input request",
@@ -71,7 +71,7 @@ const docTemplate = `{
                         "in": "body",
                         "required": true,
                         "schema": {
-                            "$ref": "#/definitions/main.PngRequest"
+                            "$ref": "#/definitions/main.DiffRequest"
                         }
                     }
                 ],
@@ -79,7 +79,7 @@ const docTemplate = `{
                     "200": {
                         "description": "OK",
                         "schema": {
-                            "$ref": "#/definitions/main.PngResponse"
+                            "$ref": "#/definitions/main.DiffResponse"
                         }
                     },
                     "400": {
@@ -91,25 +91,80 @@ const docTemplate = `{
                 }
             }
         },
-        "/preview": {
+        "/documents/{id}/pages/{page}": {
+            "get": {
+                "description": "Render a page of a pdf in /pdfs/ the first time it is requested and serve the cached image afterwards. The page is named \u003cpage\u003e.jpg, \u003cpage\u003e.png or \u003cpage\u003e.webp.",
+                "produces": [
+                    "image/jpeg",
+                    "image/png"
+                ],
+                "summary": "Get the image of a single page of a generated PDF",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "The name of the pdf without its extension",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "The page number and image format like 3.jpg",
+                        "name": "page",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Image width in pixels",
+                        "name": "width",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Image resolution",
+                        "name": "dpi",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "jpeg and webp quality from 1 to 100",
+                        "name": "quality",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK"
+                    },
+                    "400": {
+                        "description": "Bad Request"
+                    },
+                    "404": {
+                        "description": "Not Found"
+                    }
+                }
+            }
+        },
+        "/info": {
             "post": {
-                "description": "Submit urls/data to be converted to a PDF and then one image per page",
+                "description": "Return the page sizes, version, encryption, metadata, fonts and tagged or linearized status of a pdf from /pdfs/, base64 data or an \"upload\" file",
                 "consumes": [
                     "application/json",
-                    "text/xml"
+                    "multipart/form-data"
                 ],
                 "produces": [
                     "application/json"
                 ],
-                "summary": "Submit urls/data to be converted to a PDF and then one image per page",
+                "summary": "Describe a stored or uploaded PDF",
                 "parameters": [
                     {
-                        "description": "The input todo struct",
+                        "description": "The input request",
                         "name": "data",
                         "in": "body",
                         "required": true,
                         "schema": {
-                            "$ref": "#/definitions/main.PdfRequest"
+                            "$ref": "#/definitions/main.PdfInfoRequest"
                         }
                     }
                 ],
@@ -117,7 +172,7 @@ const docTemplate = `{
                     "200": {
                         "description": "OK",
                         "schema": {
-                            "$ref": "#/definitions/main.PdfPreviewResponse"
+                            "$ref": "#/definitions/main.PdfInfo"
                         }
                     },
                     "400": {
@@ -128,109 +183,1440 @@ const docTemplate = `{
                     }
                 }
             }
-        }
-    },
-    "definitions": {
-        "main.PdfPreviewResponse": {
-            "type": "object",
-            "properties": {
-                "images": {
-                    "type": "array",
-                    "items": {
-                        "type": "string"
+        },
+        "/pdf": {
+            "post": {
+                "description": "Submit urls/data to be converted to a PDF",
+                "consumes": [
+                    "application/json",
+                    "text/xml"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "Submit urls/data to be converted to a PDF",
+                "parameters": [
+                    {
+                        "description": "The input todo struct",
+                        "name": "data",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/main.PdfRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/main.PdfResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request"
+                    },
+                    "404": {
+                        "description": "Not Found"
+                    },
+                    "500": {
+                        "description": "Internal Server Error"
                     }
-                },
-                "pages": {
-                    "type": "integer"
                 }
             }
         },
-        "main.PdfRequest": {
-            "type": "object",
-            "properties": {
-                "data": {
-                    "type": "array",
-                    "items": {
-                        "type": "string"
+        "/pdf/form": {
+            "post": {
+                "description": "Set the fields of a pdf form from /pdfs/, base64 data or an \"upload\" file and optionally flatten it",
+                "consumes": [
+                    "application/json",
+                    "multipart/form-data"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "Fill the form fields of a stored or uploaded PDF",
+                "parameters": [
+                    {
+                        "description": "The input request",
+                        "name": "data",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/main.PdfFormRequest"
+                        }
                     }
-                },
-                "download": {
-                    "type": "boolean"
-                },
-                "footer": {
-                    "type": "string"
-                },
-                "header": {
-                    "type": "string"
-                },
-                "marginBottom": {
-                    "type": "number"
-                },
-                "marginLeft": {
-                    "type": "number"
-                },
-                "marginRight": {
-                    "type": "number"
-                },
-                "marginTop": {
-                    "type": "number"
-                },
-                "paperSize": {
-                    "type": "array",
-                    "items": {
-                        "type": "number"
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/main.PdfResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request"
+                    },
+                    "500": {
+                        "description": "Internal Server Error"
                     }
                 }
             }
         },
-        "main.PdfResponse": {
-            "type": "object",
-            "properties": {
-                "components": {
-                    "type": "array",
-                    "items": {
-                        "type": "string"
+        "/pdf/form/fields": {
+            "post": {
+                "description": "List the name, type, value and allowed values of every field of a pdf form from /pdfs/, base64 data or an \"upload\" file",
+                "consumes": [
+                    "application/json",
+                    "multipart/form-data"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "List the form fields of a stored or uploaded PDF",
+                "parameters": [
+                    {
+                        "description": "The input request",
+                        "name": "data",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/main.PdfFormFieldsRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/main.PdfFormFieldsResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request"
+                    },
+                    "500": {
+                        "description": "Internal Server Error"
                     }
-                },
-                "url": {
-                    "type": "string"
                 }
             }
         },
-        "main.PngRequest": {
-            "type": "object",
-            "properties": {
-                "data": {
-                    "type": "string"
-                },
-                "download": {
-                    "type": "boolean"
-                },
-                "height": {
-                    "type": "number"
-                },
-                "scale": {
-                    "type": "number"
-                },
-                "width": {
-                    "type": "number"
-                },
-                "x": {
-                    "type": "number"
-                },
-                "y": {
-                    "type": "number"
+        "/pdf/preview": {
+            "post": {
+                "description": "Validate a pdf from /pdfs/, base64 data, a url or an \"upload\" file and render one image per page like /preview",
+                "consumes": [
+                    "application/json",
+                    "multipart/form-data"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "Render page images of a stored or uploaded PDF",
+                "parameters": [
+                    {
+                        "description": "The input request",
+                        "name": "data",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/main.PdfSourcePreviewRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/main.PdfPreviewResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request"
+                    },
+                    "500": {
+                        "description": "Internal Server Error"
+                    }
                 }
             }
         },
-        "main.PngResponse": {
-            "type": "object",
-            "properties": {
-                "png": {
-                    "type": "string"
-                },
-                "url": {
-                    "type": "string"
+        "/pdf/transform": {
+            "post": {
+                "description": "Select, rotate, reorder, delete or split the pages of a pdf from /pdfs/, base64 data or an \"upload\" file",
+                "consumes": [
+                    "application/json",
+                    "text/xml",
+                    "multipart/form-data"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "Apply page operations to a stored or uploaded PDF",
+                "parameters": [
+                    {
+                        "description": "The input request",
+                        "name": "data",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/main.PdfTransformRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/main.PdfResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request"
+                    },
+                    "500": {
+                        "description": "Internal Server Error"
+                    }
+                }
+            }
+        },
+        "/png": {
+            "post": {
+                "description": "Submit a single url or data to be converted to a png",
+                "consumes": [
+                    "application/json",
+                    "text/xml"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "Submit a single url or data to be converted to a png",
+                "parameters": [
+                    {
+                        "description": "The input request",
+                        "name": "data",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/main.PngRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/main.PngResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request"
+                    },
+                    "404": {
+                        "description": "Not Found"
+                    },
+                    "500": {
+                        "description": "Internal Server Error"
+                    }
+                }
+            }
+        },
+        "/png/animation": {
+            "post": {
+                "description": "Record a page while it loads, and optionally scrolls to the bottom, as an animated gif or webp",
+                "consumes": [
+                    "application/json",
+                    "text/xml"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "Record an animation of a url or data loading",
+                "parameters": [
+                    {
+                        "description": "The input request",
+                        "name": "data",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/main.AnimationRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/main.AnimationResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request"
+                    },
+                    "500": {
+                        "description": "Internal Server Error"
+                    }
+                }
+            }
+        },
+        "/png/batch": {
+            "post": {
+                "description": "Capture many sources concurrently, each item is the html or url to capture or a screenshot request whose options replace the defaults",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "Submit many urls or data to be converted to images",
+                "parameters": [
+                    {
+                        "description": "The input request",
+                        "name": "data",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/main.PngBatchRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/main.PngBatchResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request"
+                    },
+                    "500": {
+                        "description": "Internal Server Error"
+                    }
+                }
+            }
+        },
+        "/preview": {
+            "post": {
+                "description": "Submit urls/data to be converted to a PDF and then one image per page",
+                "consumes": [
+                    "application/json",
+                    "text/xml"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "Submit urls/data to be converted to a PDF and then one image per page",
+                "parameters": [
+                    {
+                        "description": "The input todo struct",
+                        "name": "data",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/main.PdfRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/main.PdfPreviewResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request"
+                    },
+                    "404": {
+                        "description": "Not Found"
+                    },
+                    "500": {
+                        "description": "Internal Server Error"
+                    }
+                }
+            }
+        },
+        "/text": {
+            "post": {
+                "description": "Extract the text of every page of a pdf from /pdfs/, base64 data or an \"upload\" file, optionally with word boxes in preview image pixels",
+                "consumes": [
+                    "application/json",
+                    "multipart/form-data"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "Extract the text of a stored or uploaded PDF",
+                "parameters": [
+                    {
+                        "description": "The input request",
+                        "name": "data",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/main.PdfTextRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/main.PdfTextResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request"
+                    },
+                    "500": {
+                        "description": "Internal Server Error"
+                    }
+                }
+            }
+        }
+    },
+    "definitions": {
+        "main.AnimationRequest": {
+            "type": "object",
+            "properties": {
+                "data": {
+                    "type": "string"
+                },
+                "download": {
+                    "type": "boolean"
+                },
+                "duration": {
+                    "description": "Duration is the length of the recording in seconds",
+                    "type": "number"
+                },
+                "format": {
+                    "description": "Format is gif (default) or webp",
+                    "type": "string"
+                },
+                "frameRate": {
+                    "type": "integer"
+                },
+                "height": {
+                    "type": "integer"
+                },
+                "quality": {
+                    "description": "Quality applies to webp animations, without it the frames are lossless",
+                    "type": "integer"
+                },
+                "scroll": {
+                    "type": "boolean"
+                },
+                "width": {
+                    "type": "integer"
+                }
+            }
+        },
+        "main.AnimationResponse": {
+            "type": "object",
+            "properties": {
+                "animation": {
+                    "type": "string"
+                },
+                "duration": {
+                    "type": "number"
+                },
+                "format": {
+                    "type": "string"
+                },
+                "frames": {
+                    "type": "integer"
+                },
+                "url": {
+                    "type": "string"
+                }
+            }
+        },
+        "main.ArchiveRequest": {
+            "type": "object",
+            "properties": {
+                "data": {
+                    "type": "string"
+                },
+                "download": {
+                    "type": "boolean"
+                },
+                "format": {
+                    "description": "Format is mhtml (default), a web archive with every resource of the page, or html, the document alone",
+                    "type": "string"
+                }
+            }
+        },
+        "main.ArchiveResponse": {
+            "type": "object",
+            "properties": {
+                "archive": {
+                    "type": "string"
+                },
+                "format": {
+                    "type": "string"
+                },
+                "title": {
+                    "type": "string"
+                },
+                "url": {
+                    "type": "string"
+                }
+            }
+        },
+        "main.DiffPage": {
+            "type": "object",
+            "properties": {
+                "changedPixels": {
+                    "type": "integer"
+                },
+                "diff": {
+                    "type": "string"
+                },
+                "error": {
+                    "type": "string"
+                },
+                "height": {
+                    "type": "integer"
+                },
+                "mismatch": {
+                    "type": "number"
+                },
+                "page": {
+                    "type": "integer"
+                },
+                "passed": {
+                    "type": "boolean"
+                },
+                "width": {
+                    "type": "integer"
+                }
+            }
+        },
+        "main.DiffRegion": {
+            "type": "object",
+            "properties": {
+                "height": {
+                    "type": "integer"
+                },
+                "page": {
+                    "type": "integer"
+                },
+                "width": {
+                    "type": "integer"
+                },
+                "x": {
+                    "type": "integer"
+                },
+                "y": {
+                    "type": "integer"
+                }
+            }
+        },
+        "main.DiffRequest": {
+            "type": "object",
+            "properties": {
+                "actual": {
+                    "$ref": "#/definitions/main.DiffSource"
+                },
+                "antiAliasing": {
+                    "description": "AntiAliasing ignores changed pixels that match a neighbouring pixel in the other image both ways",
+                    "type": "boolean"
+                },
+                "dpi": {
+                    "description": "Dpi is the resolution pdf pages are rendered at, without it the longest side of a page is 1024 pixels",
+                    "type": "integer"
+                },
+                "expected": {
+                    "$ref": "#/definitions/main.DiffSource"
+                },
+                "ignoreRegions": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/main.DiffRegion"
+                    }
+                },
+                "pdf": {
+                    "$ref": "#/definitions/main.PdfRequest"
+                },
+                "screenshot": {
+                    "$ref": "#/definitions/main.PngRequest"
+                },
+                "threshold": {
+                    "description": "Threshold is the largest mismatch percentage a page may have and still pass",
+                    "type": "number"
+                },
+                "tolerance": {
+                    "description": "Tolerance is the largest difference of a color channel, 0-255, that still counts as the same color",
+                    "type": "integer"
+                }
+            }
+        },
+        "main.DiffResponse": {
+            "type": "object",
+            "properties": {
+                "pages": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/main.DiffPage"
+                    }
+                },
+                "passed": {
+                    "type": "boolean"
+                },
+                "threshold": {
+                    "type": "number"
+                }
+            }
+        },
+        "main.DiffSource": {
+            "type": "object",
+            "properties": {
+                "data": {
+                    "type": "string"
+                },
+                "file": {
+                    "type": "string"
+                }
+            }
+        },
+        "main.PdfEncryption": {
+            "type": "object",
+            "properties": {
+                "allowAnnotate": {
+                    "type": "boolean"
+                },
+                "allowCopy": {
+                    "type": "boolean"
+                },
+                "allowModify": {
+                    "type": "boolean"
+                },
+                "allowPrint": {
+                    "type": "boolean"
+                },
+                "keyLength": {
+                    "type": "integer"
+                },
+                "ownerPassword": {
+                    "type": "string"
+                },
+                "userPassword": {
+                    "type": "string"
+                }
+            }
+        },
+        "main.PdfFont": {
+            "type": "object",
+            "properties": {
+                "embedded": {
+                    "type": "boolean"
+                },
+                "encoding": {
+                    "type": "string"
+                },
+                "name": {
+                    "type": "string"
+                },
+                "subset": {
+                    "description": "Subset fonts only embed the glyphs the document uses",
+                    "type": "boolean"
+                },
+                "type": {
+                    "type": "string"
+                }
+            }
+        },
+        "main.PdfFormField": {
+            "type": "object",
+            "properties": {
+                "editable": {
+                    "type": "boolean"
+                },
+                "format": {
+                    "type": "string"
+                },
+                "locked": {
+                    "type": "boolean"
+                },
+                "maxLength": {
+                    "type": "integer"
+                },
+                "multiline": {
+                    "type": "boolean"
+                },
+                "multiple": {
+                    "type": "boolean"
+                },
+                "name": {
+                    "type": "string"
+                },
+                "options": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "pages": {
+                    "type": "array",
+                    "items": {
+                        "type": "integer"
+                    }
+                },
+                "type": {
+                    "type": "string"
+                },
+                "value": {}
+            }
+        },
+        "main.PdfFormFieldsRequest": {
+            "type": "object",
+            "properties": {
+                "data": {
+                    "type": "string"
+                },
+                "file": {
+                    "type": "string"
+                }
+            }
+        },
+        "main.PdfFormFieldsResponse": {
+            "type": "object",
+            "properties": {
+                "fields": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/main.PdfFormField"
+                    }
+                }
+            }
+        },
+        "main.PdfFormRequest": {
+            "type": "object",
+            "properties": {
+                "data": {
+                    "type": "string"
+                },
+                "download": {
+                    "type": "boolean"
+                },
+                "fields": {
+                    "type": "object",
+                    "additionalProperties": {}
+                },
+                "file": {
+                    "type": "string"
+                },
+                "flatten": {
+                    "type": "boolean"
+                }
+            }
+        },
+        "main.PdfImposition": {
+            "type": "object",
+            "properties": {
+                "binding": {
+                    "type": "string"
+                },
+                "border": {
+                    "type": "boolean"
+                },
+                "guides": {
+                    "type": "boolean"
+                },
+                "gutter": {
+                    "type": "number"
+                },
+                "layout": {
+                    "type": "string"
+                },
+                "order": {
+                    "type": "string"
+                },
+                "pagesPerSheet": {
+                    "type": "integer"
+                },
+                "sheetDimensions": {
+                    "type": "array",
+                    "items": {
+                        "type": "number"
+                    }
+                },
+                "sheetSize": {
+                    "type": "string"
+                }
+            }
+        },
+        "main.PdfInfo": {
+            "type": "object",
+            "properties": {
+                "encrypted": {
+                    "type": "boolean"
+                },
+                "fonts": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/main.PdfFont"
+                    }
+                },
+                "form": {
+                    "type": "boolean"
+                },
+                "linearized": {
+                    "type": "boolean"
+                },
+                "metadata": {
+                    "$ref": "#/definitions/main.PdfMetadata"
+                },
+                "pageSizes": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/main.PdfPageSize"
+                    }
+                },
+                "pages": {
+                    "type": "integer"
+                },
+                "signed": {
+                    "type": "boolean"
+                },
+                "tagged": {
+                    "type": "boolean"
+                },
+                "version": {
+                    "type": "string"
+                }
+            }
+        },
+        "main.PdfInfoRequest": {
+            "type": "object",
+            "properties": {
+                "data": {
+                    "type": "string"
+                },
+                "file": {
+                    "type": "string"
+                }
+            }
+        },
+        "main.PdfMetadata": {
+            "type": "object",
+            "properties": {
+                "author": {
+                    "type": "string"
+                },
+                "creationDate": {
+                    "type": "string"
+                },
+                "creator": {
+                    "type": "string"
+                },
+                "keywords": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "modificationDate": {
+                    "type": "string"
+                },
+                "producer": {
+                    "type": "string"
+                },
+                "properties": {
+                    "type": "object",
+                    "additionalProperties": {
+                        "type": "string"
+                    }
+                },
+                "subject": {
+                    "type": "string"
+                },
+                "title": {
+                    "type": "string"
+                }
+            }
+        },
+        "main.PdfPageSize": {
+            "type": "object",
+            "properties": {
+                "height": {
+                    "type": "number"
+                },
+                "page": {
+                    "type": "integer"
+                },
+                "rotation": {
+                    "type": "integer"
+                },
+                "width": {
+                    "type": "number"
+                }
+            }
+        },
+        "main.PdfPreviewOptions": {
+            "type": "object",
+            "properties": {
+                "format": {
+                    "type": "string"
+                },
+                "lazy": {
+                    "description": "Lazy previews are rendered by /documents when they are first requested",
+                    "type": "boolean"
+                },
+                "pages": {
+                    "type": "string"
+                },
+                "quality": {
+                    "type": "integer"
+                },
+                "sizes": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/main.PdfPreviewSize"
+                    }
+                }
+            }
+        },
+        "main.PdfPreviewPage": {
+            "type": "object",
+            "properties": {
+                "images": {
+                    "type": "object",
+                    "additionalProperties": {
+                        "type": "string"
+                    }
+                },
+                "page": {
+                    "type": "integer"
+                }
+            }
+        },
+        "main.PdfPreviewResponse": {
+            "type": "object",
+            "properties": {
+                "images": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "info": {
+                    "$ref": "#/definitions/main.PdfInfo"
+                },
+                "pages": {
+                    "type": "integer"
+                },
+                "previews": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/main.PdfPreviewPage"
+                    }
+                },
+                "text": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/main.PdfTextPage"
+                    }
+                }
+            }
+        },
+        "main.PdfPreviewSize": {
+            "type": "object",
+            "properties": {
+                "dpi": {
+                    "type": "integer"
+                },
+                "name": {
+                    "type": "string"
+                },
+                "width": {
+                    "type": "integer"
+                }
+            }
+        },
+        "main.PdfRequest": {
+            "type": "object",
+            "properties": {
+                "continuous": {
+                    "type": "boolean"
+                },
+                "cover": {
+                    "type": "string"
+                },
+                "data": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "download": {
+                    "type": "boolean"
+                },
+                "duplex": {
+                    "type": "boolean"
+                },
+                "encryption": {
+                    "$ref": "#/definitions/main.PdfEncryption"
+                },
+                "footer": {
+                    "type": "string"
+                },
+                "formFields": {
+                    "type": "boolean"
+                },
+                "header": {
+                    "type": "string"
+                },
+                "imposition": {
+                    "$ref": "#/definitions/main.PdfImposition"
+                },
+                "marginBottom": {
+                    "type": "number"
+                },
+                "marginLeft": {
+                    "type": "number"
+                },
+                "marginRight": {
+                    "type": "number"
+                },
+                "marginTop": {
+                    "type": "number"
+                },
+                "markdownStylesheet": {
+                    "description": "MarkdownStylesheet replaces the stylesheet markdown entries are rendered with",
+                    "type": "string"
+                },
+                "paperSize": {
+                    "type": "array",
+                    "items": {
+                        "type": "number"
+                    }
+                },
+                "preview": {
+                    "$ref": "#/definitions/main.PdfPreviewOptions"
+                },
+                "selectors": {
+                    "description": "Selectors holds a css selector for the data entry at the same index, only the first visible element it matches\nis printed. Entries without one, or with an empty one, are printed in full.",
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "separator": {
+                    "type": "string"
+                },
+                "signature": {
+                    "$ref": "#/definitions/main.PdfSignature"
+                },
+                "tableOfContents": {
+                    "$ref": "#/definitions/main.PdfTableOfContents"
+                },
+                "text": {
+                    "type": "boolean"
+                },
+                "textWords": {
+                    "type": "boolean"
+                }
+            }
+        },
+        "main.PdfResponse": {
+            "type": "object",
+            "properties": {
+                "components": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "info": {
+                    "$ref": "#/definitions/main.PdfInfo"
+                },
+                "signature": {
+                    "$ref": "#/definitions/main.PdfSignatureDetails"
+                },
+                "url": {
+                    "type": "string"
+                }
+            }
+        },
+        "main.PdfSignature": {
+            "type": "object",
+            "properties": {
+                "contactInfo": {
+                    "type": "string"
+                },
+                "identity": {
+                    "type": "string"
+                },
+                "location": {
+                    "type": "string"
+                },
+                "page": {
+                    "type": "integer"
+                },
+                "reason": {
+                    "type": "string"
+                },
+                "rect": {
+                    "type": "array",
+                    "items": {
+                        "type": "number"
+                    }
+                },
+                "timestamp": {
+                    "type": "boolean"
+                },
+                "visible": {
+                    "type": "boolean"
+                }
+            }
+        },
+        "main.PdfSignatureDetails": {
+            "type": "object",
+            "properties": {
+                "contactInfo": {
+                    "type": "string"
+                },
+                "identity": {
+                    "type": "string"
+                },
+                "issuer": {
+                    "type": "string"
+                },
+                "location": {
+                    "type": "string"
+                },
+                "page": {
+                    "type": "integer"
+                },
+                "problems": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "reason": {
+                    "type": "string"
+                },
+                "selfSigned": {
+                    "type": "boolean"
+                },
+                "serialNumber": {
+                    "type": "string"
+                },
+                "signingTime": {
+                    "type": "string"
+                },
+                "status": {
+                    "type": "string"
+                },
+                "subject": {
+                    "type": "string"
+                },
+                "timestamp": {
+                    "type": "string"
+                },
+                "trusted": {
+                    "type": "boolean"
+                },
+                "valid": {
+                    "type": "boolean"
+                },
+                "visible": {
+                    "type": "boolean"
+                }
+            }
+        },
+        "main.PdfSourcePreviewRequest": {
+            "type": "object",
+            "properties": {
+                "data": {
+                    "type": "string"
+                },
+                "file": {
+                    "type": "string"
+                },
+                "preview": {
+                    "$ref": "#/definitions/main.PdfPreviewOptions"
+                },
+                "text": {
+                    "type": "boolean"
+                },
+                "textWords": {
+                    "type": "boolean"
+                }
+            }
+        },
+        "main.PdfTableOfContents": {
+            "type": "object",
+            "properties": {
+                "title": {
+                    "type": "string"
+                },
+                "titles": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                }
+            }
+        },
+        "main.PdfTextPage": {
+            "type": "object",
+            "properties": {
+                "height": {
+                    "type": "integer"
+                },
+                "page": {
+                    "type": "integer"
+                },
+                "text": {
+                    "type": "string"
+                },
+                "width": {
+                    "type": "integer"
+                },
+                "words": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/main.PdfTextWord"
+                    }
+                }
+            }
+        },
+        "main.PdfTextRequest": {
+            "type": "object",
+            "properties": {
+                "data": {
+                    "type": "string"
+                },
+                "file": {
+                    "type": "string"
+                },
+                "words": {
+                    "type": "boolean"
+                }
+            }
+        },
+        "main.PdfTextResponse": {
+            "type": "object",
+            "properties": {
+                "pages": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/main.PdfTextPage"
+                    }
+                },
+                "text": {
+                    "type": "string"
+                }
+            }
+        },
+        "main.PdfTextWord": {
+            "type": "object",
+            "properties": {
+                "height": {
+                    "type": "number"
+                },
+                "text": {
+                    "type": "string"
+                },
+                "width": {
+                    "type": "number"
+                },
+                "x": {
+                    "type": "number"
+                },
+                "y": {
+                    "type": "number"
+                }
+            }
+        },
+        "main.PdfTransformOperation": {
+            "type": "object",
+            "properties": {
+                "operation": {
+                    "type": "string"
+                },
+                "order": {
+                    "type": "array",
+                    "items": {
+                        "type": "integer"
+                    }
+                },
+                "pages": {
+                    "type": "string"
+                },
+                "rotation": {
+                    "type": "integer"
+                },
+                "span": {
+                    "type": "integer"
+                }
+            }
+        },
+        "main.PdfTransformRequest": {
+            "type": "object",
+            "properties": {
+                "data": {
+                    "type": "string"
+                },
+                "download": {
+                    "type": "boolean"
+                },
+                "file": {
+                    "type": "string"
+                },
+                "operations": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/main.PdfTransformOperation"
+                    }
+                }
+            }
+        },
+        "main.PngBatchRequest": {
+            "type": "object",
+            "properties": {
+                "defaults": {
+                    "$ref": "#/definitions/main.PngRequest"
+                },
+                "download": {
+                    "description": "Download returns a zip of every image instead of the json results",
+                    "type": "boolean"
+                },
+                "items": {
+                    "type": "array",
+                    "items": {
+                        "type": "object"
+                    }
+                }
+            }
+        },
+        "main.PngBatchResponse": {
+            "type": "object",
+            "properties": {
+                "results": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/main.PngBatchResult"
+                    }
+                }
+            }
+        },
+        "main.PngBatchResult": {
+            "type": "object",
+            "properties": {
+                "error": {
+                    "type": "string"
+                },
+                "index": {
+                    "type": "integer"
+                },
+                "result": {
+                    "$ref": "#/definitions/main.PngResponse"
+                },
+                "status": {
+                    "type": "integer"
+                },
+                "success": {
+                    "type": "boolean"
+                }
+            }
+        },
+        "main.PngRequest": {
+            "type": "object",
+            "properties": {
+                "data": {
+                    "type": "string"
+                },
+                "download": {
+                    "type": "boolean"
+                },
+                "format": {
+                    "description": "Format is png (default), jpeg or webp, quality applies to jpeg and webp",
+                    "type": "string"
+                },
+                "height": {
+                    "type": "number"
+                },
+                "padding": {
+                    "type": "number"
+                },
+                "quality": {
+                    "type": "integer"
+                },
+                "scale": {
+                    "type": "number"
+                },
+                "selector": {
+                    "type": "string"
+                },
+                "viewports": {
+                    "description": "Viewports captures the page once per viewport, form submissions provide them as a json string",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/main.PngViewport"
+                    }
+                },
+                "width": {
+                    "type": "number"
+                },
+                "x": {
+                    "type": "number"
+                },
+                "y": {
+                    "type": "number"
+                }
+            }
+        },
+        "main.PngResponse": {
+            "type": "object",
+            "properties": {
+                "format": {
+                    "type": "string"
+                },
+                "images": {
+                    "description": "Images lists every image when a selector matched several elements",
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "png": {
+                    "type": "string"
+                },
+                "url": {
+                    "type": "string"
+                },
+                "viewports": {
+                    "description": "Viewports lists the images of every requested viewport by name",
+                    "type": "object",
+                    "additionalProperties": {
+                        "type": "array",
+                        "items": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "main.PngViewport": {
+            "type": "object",
+            "properties": {
+                "deviceScaleFactor": {
+                    "type": "number"
+                },
+                "height": {
+                    "type": "integer"
+                },
+                "mobile": {
+                    "type": "boolean"
+                },
+                "name": {
+                    "type": "string"
+                },
+                "width": {
+                    "type": "integer"
                 }
             }
         }
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/pdfcpu/pdfcpu/pkg/api"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/model"
+)
+
+type PdfEncryption struct {
+	UserPassword  string `json:"userPassword" form:"userPassword"`
+	OwnerPassword string `json:"ownerPassword" form:"ownerPassword"`
+	KeyLength     int    `json:"keyLength" form:"keyLength"`
+	AllowPrint    bool   `json:"allowPrint" form:"allowPrint"`
+	AllowCopy     bool   `json:"allowCopy" form:"allowCopy"`
+	AllowModify   bool   `json:"allowModify" form:"allowModify"`
+	AllowAnnotate bool   `json:"allowAnnotate" form:"allowAnnotate"`
+}
+
+func getEncryptionConfiguration(encryption *PdfEncryption) (*model.Configuration, error) {
+	if encryption.OwnerPassword == "" {
+		return nil, errors.New("ownerPassword is required when encrypting a pdf")
+	}
+
+	keyLength := encryption.KeyLength
+	if keyLength == 0 {
+		keyLength = 256
+	}
+
+	if keyLength != 128 && keyLength != 256 {
+		return nil, fmt.Errorf("unsupported encryption key length %d, expected 128 or 256", keyLength)
+	}
+
+	conf := model.NewAESConfiguration(encryption.UserPassword, encryption.OwnerPassword, keyLength)
+
+	permissions := model.PermissionsNone
+	if encryption.AllowPrint {
+		permissions |= model.PermissionPrintRev2 | model.PermissionPrintRev3
+	}
+
+	if encryption.AllowCopy {
+		permissions |= model.PermissionExtract | model.PermissionExtractRev3
+	}
+
+	if encryption.AllowModify {
+		permissions |= model.PermissionModify | model.PermissionAssembleRev3
+	}
+
+	if encryption.AllowAnnotate {
+		permissions |= model.PermissionModAnnFillForm | model.PermissionFillRev3
+	}
+
+	conf.Permissions = permissions
+
+	return conf, nil
+}
+
+// encryptPdf encrypts pdfFile in place, it must be the last step applied to a pdf
+func encryptPdf(pdfFile string, encryption *PdfEncryption) error {
+	conf, err := getEncryptionConfiguration(encryption)
+	if err != nil {
+		return err
+	}
+
+	if err := api.EncryptFile(pdfFile, "", conf); err != nil {
+		return fmt.Errorf("unable to encrypt pdf: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,528 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/pdfcpu/pdfcpu/pkg/api"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/form"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/model"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/types"
+)
+
+const (
+	FormFieldText     string = "text"
+	FormFieldDate     string = "date"
+	FormFieldCheckBox string = "checkbox"
+	FormFieldRadio    string = "radio"
+	FormFieldComboBox string = "combobox"
+	FormFieldListBox  string = "listbox"
+)
+
+// Annotation flags that keep a widget from being drawn
+const (
+	annotationFlagHidden = 1 << 1
+	annotationFlagNoView = 1 << 5
+)
+
+type PdfFormRequest struct {
+	PdfSource
+	Download bool           `json:"download" form:"download"`
+	Flatten  bool           `json:"flatten" form:"flatten"`
+	Fields   map[string]any `json:"fields" form:"-"`
+}
+
+type PdfFormFieldsRequest struct {
+	PdfSource
+}
+
+type PdfFormField struct {
+	Name      string   `json:"name"`
+	Type      string   `json:"type"`
+	Value     any      `json:"value"`
+	Options   []string `json:"options,omitempty"`
+	Editable  bool     `json:"editable,omitempty"`
+	Multiple  bool     `json:"multiple,omitempty"`
+	Multiline bool     `json:"multiline,omitempty"`
+	MaxLength int      `json:"maxLength,omitempty"`
+	Format    string   `json:"format,omitempty"`
+	Locked    bool     `json:"locked"`
+	Pages     []int    `json:"pages"`
+}
+
+type PdfFormFieldsResponse struct {
+	Fields []PdfFormField `json:"fields"`
+}
+
+func exportPdfForm(pdfData []byte) (*form.Form, error) {
+	formGroup, err := api.ExportForm(bytes.NewReader(pdfData), "form", nil)
+	if err != nil {
+		if errors.Is(err, api.ErrNoFormFieldsAffected) {
+			return nil, errors.New("the pdf has no form fields")
+		}
+
+		return nil, fmt.Errorf("unable to read form: %w", err)
+	}
+
+	if len(formGroup.Forms) == 0 {
+		return nil, errors.New("the pdf has no form fields")
+	}
+
+	return &formGroup.Forms[0], nil
+}
+
+// getPdfFormFields lists the fields of the form in pdfData sorted by name
+func getPdfFormFields(pdfData []byte) ([]PdfFormField, error) {
+	pdfForm, err := exportPdfForm(pdfData)
+	if err != nil {
+		return nil, err
+	}
+
+	var fields []PdfFormField
+	for _, field := range pdfForm.TextFields {
+		fields = append(fields, PdfFormField{Name: field.Name, Type: FormFieldText, Value: field.Value, Multiline: field.Multiline, MaxLength: field.MaxLen, Locked: field.Locked, Pages: field.Pages})
+	}
+
+	for _, field := range pdfForm.DateFields {
+		fields = append(fields, PdfFormField{Name: field.Name, Type: FormFieldDate, Value: field.Value, Format: field.Format, Locked: field.Locked, Pages: field.Pages})
+	}
+
+	for _, field := range pdfForm.CheckBoxes {
+		fields = append(fields, PdfFormField{Name: field.Name, Type: FormFieldCheckBox, Value: field.Value, Locked: field.Locked, Pages: field.Pages})
+	}
+
+	for _, field := range pdfForm.RadioButtonGroups {
+		fields = append(fields, PdfFormField{Name: field.Name, Type: FormFieldRadio, Value: field.Value, Options: field.Options, Locked: field.Locked, Pages: field.Pages})
+	}
+
+	for _, field := range pdfForm.ComboBoxes {
+		fields = append(fields, PdfFormField{Name: field.Name, Type: FormFieldComboBox, Value: field.Value, Options: field.Options, Editable: field.Editable, Locked: field.Locked, Pages: field.Pages})
+	}
+
+	for _, field := range pdfForm.ListBoxes {
+		values := field.Values
+		if values == nil {
+			values = []string{}
+		}
+		fields = append(fields, PdfFormField{Name: field.Name, Type: FormFieldListBox, Value: values, Options: field.Options, Multiple: field.Multi, Locked: field.Locked, Pages: field.Pages})
+	}
+
+	sort.SliceStable(fields, func(i, j int) bool {
+		return fields[i].Name < fields[j].Name
+	})
+
+	return fields, nil
+}
+
+// fillPdfForm sets the fields of the form in pdfData to values, keyed by field name, and optionally flattens it
+func fillPdfForm(pdfData []byte, values map[string]any, flatten bool) ([]byte, error) {
+	if len(values) > 0 {
+		pdfForm, err := exportPdfForm(pdfData)
+		if err != nil {
+			return nil, err
+		}
+
+		var unknownFields []string
+		for name, value := range values {
+			found, err := setFormFieldValue(pdfForm, name, value)
+			if err != nil {
+				return nil, fmt.Errorf("invalid value for field %q: %w", name, err)
+			}
+
+			if !found {
+				unknownFields = append(unknownFields, name)
+			}
+		}
+
+		if len(unknownFields) > 0 {
+			sort.Strings(unknownFields)
+			return nil, fmt.Errorf("unknown form fields: %s", strings.Join(unknownFields, ", "))
+		}
+
+		formJson, err := json.Marshal(form.FormGroup{Forms: []form.Form{*pdfForm}})
+		if err != nil {
+			return nil, err
+		}
+
+		var output bytes.Buffer
+		err = api.FillForm(bytes.NewReader(pdfData), bytes.NewReader(formJson), &output, nil)
+		if err != nil && !errors.Is(err, api.ErrNoFormFieldsAffected) {
+			return nil, fmt.Errorf("unable to fill form: %w", err)
+		}
+
+		// Nothing is written when the fields already had the requested values
+		if err == nil {
+			pdfData = output.Bytes()
+		}
+	}
+
+	if flatten {
+		return flattenPdfForm(pdfData)
+	}
+
+	return pdfData, nil
+}
+
+// setFormFieldValue reports whether a field called name exists in pdfForm
+func setFormFieldValue(pdfForm *form.Form, name string, value any) (bool, error) {
+	for _, field := range pdfForm.TextFields {
+		if field.Name == name {
+			text, err := formTextValue(value)
+			field.Value = text
+			return true, err
+		}
+	}
+
+	for _, field := range pdfForm.DateFields {
+		if field.Name == name {
+			text, err := formTextValue(value)
+			field.Value = text
+			return true, err
+		}
+	}
+
+	for _, field := range pdfForm.CheckBoxes {
+		if field.Name == name {
+			checked, err := formBoolValue(value)
+			field.Value = checked
+			return true, err
+		}
+	}
+
+	for _, field := range pdfForm.RadioButtonGroups {
+		if field.Name == name {
+			option, err := formOptionValue(value, field.Options)
+			field.Value = option
+			return true, err
+		}
+	}
+
+	for _, field := range pdfForm.ComboBoxes {
+		if field.Name == name {
+			var option string
+			var err error
+			if field.Editable {
+				option, err = formTextValue(value)
+			} else {
+				option, err = formOptionValue(value, field.Options)
+			}
+			field.Value = option
+			return true, err
+		}
+	}
+
+	for _, field := range pdfForm.ListBoxes {
+		if field.Name == name {
+			var selected []string
+			entries, isList := value.([]any)
+			if !isList {
+				entries = []any{value}
+			}
+
+			if len(entries) > 1 && !field.Multi {
+				return true, errors.New("only one option may be selected")
+			}
+
+			for _, entry := range entries {
+				option, err := formOptionValue(entry, field.Options)
+				if err != nil {
+					return true, err
+				}
+				selected = append(selected, option)
+			}
+
+			field.Values = selected
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+func formTextValue(value any) (string, error) {
+	switch typed := value.(type) {
+	case string:
+		return typed, nil
+	case float64, bool:
+		return fmt.Sprint(typed), nil
+	case nil:
+		return "", nil
+	}
+
+	return "", errors.New("expected a string")
+}
+
+func formBoolValue(value any) (bool, error) {
+	switch typed := value.(type) {
+	case bool:
+		return typed, nil
+	case string:
+		switch strings.ToLower(typed) {
+		case "yes", "on":
+			return true, nil
+		case "no", "off", "":
+			return false, nil
+		}
+
+		checked, err := strconv.ParseBool(typed)
+		if err != nil {
+			return false, errors.New("expected true or false")
+		}
+
+		return checked, nil
+	}
+
+	return false, errors.New("expected true or false")
+}
+
+func formOptionValue(value any, options []string) (string, error) {
+	option, err := formTextValue(value)
+	if err != nil {
+		return "", err
+	}
+
+	if option != "" && !slices.Contains(options, option) {
+		return "", fmt.Errorf("expected one of %s", strings.Join(options, ", "))
+	}
+
+	return option, nil
+}
+
+// flattenPdfForm draws the appearance of every form field into the page content and removes the form
+func flattenPdfForm(pdfData []byte) ([]byte, error) {
+	conf := model.NewDefaultConfiguration()
+	ctx, err := api.ReadValidateAndOptimize(bytes.NewReader(pdfData), conf)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read pdf: %w", err)
+	}
+
+	// Resources can be shared between pages so appearance names have to be unique across the document
+	appearanceCount := 0
+
+	for pageNumber := 1; pageNumber <= ctx.PageCount; pageNumber++ {
+		pageDict, _, _, err := ctx.PageDict(pageNumber, true)
+		if err != nil {
+			return nil, err
+		}
+
+		annotations, err := ctx.DereferenceArray(pageDict["Annots"])
+		if err != nil {
+			return nil, err
+		}
+
+		var keptAnnotations types.Array
+		var content bytes.Buffer
+		appearances := types.Dict{}
+		widgets := 0
+
+		for _, annotationObject := range annotations {
+			annotation, err := ctx.DereferenceDict(annotationObject)
+			if err != nil || annotation == nil {
+				continue
+			}
+
+			if subtype := annotation.NameEntry("Subtype"); subtype == nil || *subtype != "Widget" {
+				keptAnnotations = append(keptAnnotations, annotationObject)
+				continue
+			}
+			widgets++
+
+			if flags := annotation.IntEntry("F"); flags != nil && *flags&(annotationFlagHidden|annotationFlagNoView) != 0 {
+				continue
+			}
+
+			appearanceRef, appearance := widgetAppearance(ctx, annotation)
+			if appearance == nil {
+				continue
+			}
+
+			transform, ok := appearanceTransform(ctx, annotation, appearance)
+			if !ok {
+				continue
+			}
+
+			appearanceCount++
+			name := fmt.Sprintf("FlattenedField%d", appearanceCount)
+			appearances[name] = *appearanceRef
+			fmt.Fprintf(&content, "q %s cm /%s Do Q\n", transform, name)
+		}
+
+		if widgets == 0 {
+			continue
+		}
+
+		if len(keptAnnotations) > 0 {
+			pageDict["Annots"] = keptAnnotations
+		} else {
+			pageDict.Delete("Annots")
+		}
+
+		if len(appearances) == 0 {
+			continue
+		}
+
+		resources, err := ctx.DereferenceDict(pageDict["Resources"])
+		if err != nil {
+			return nil, err
+		}
+
+		if resources == nil {
+			resources = types.Dict{}
+			pageDict["Resources"] = resources
+		}
+
+		xObjects, err := ctx.DereferenceDict(resources["XObject"])
+		if err != nil {
+			return nil, err
+		}
+
+		if xObjects == nil {
+			xObjects = types.Dict{}
+			resources["XObject"] = xObjects
+		}
+
+		for name, appearanceRef := range appearances {
+			xObjects[name] = appearanceRef
+		}
+
+		// Isolate the existing content so its graphics state does not leak into the fields
+		saveRef, err := newContentStream(ctx, []byte("q\n"))
+		if err != nil {
+			return nil, err
+		}
+
+		fieldsRef, err := newContentStream(ctx, append([]byte("Q\n"), content.Bytes()...))
+		if err != nil {
+			return nil, err
+		}
+
+		contents := types.Array{*saveRef}
+		switch existing := pageDict["Contents"].(type) {
+		case types.IndirectRef:
+			if array, err := ctx.DereferenceArray(existing); err == nil && array != nil {
+				contents = append(contents, array...)
+			} else {
+				contents = append(contents, existing)
+			}
+		case types.Array:
+			contents = append(contents, existing...)
+		}
+		pageDict["Contents"] = append(contents, *fieldsRef)
+	}
+
+	catalog, err := ctx.Catalog()
+	if err != nil {
+		return nil, err
+	}
+	catalog.Delete("AcroForm")
+
+	var output bytes.Buffer
+	if err := api.Write(ctx, &output, conf); err != nil {
+		return nil, fmt.Errorf("unable to write pdf: %w", err)
+	}
+
+	return output.Bytes(), nil
+}
+
+// widgetAppearance returns the normal appearance of a widget in its current state
+func widgetAppearance(ctx *model.Context, annotation types.Dict) (*types.IndirectRef, *types.StreamDict) {
+	appearances, err := ctx.DereferenceDict(annotation["AP"])
+	if err != nil || appearances == nil {
+		return nil, nil
+	}
+
+	normal := appearances["N"]
+	if states, err := ctx.DereferenceDict(normal); err == nil && states != nil {
+		// Check boxes and radio buttons have an appearance for each state
+		state := annotation.NameEntry("AS")
+		if state == nil {
+			return nil, nil
+		}
+		normal = states[*state]
+	}
+
+	appearanceRef, ok := normal.(types.IndirectRef)
+	if !ok {
+		return nil, nil
+	}
+
+	appearance, _, err := ctx.DereferenceStreamDict(appearanceRef)
+	if err != nil || appearance == nil {
+		return nil, nil
+	}
+
+	return &appearanceRef, appearance
+}
+
+// appearanceTransform returns the matrix that maps the appearance bounding box onto the widget rectangle
+func appearanceTransform(ctx *model.Context, annotation types.Dict, appearance *types.StreamDict) (string, bool) {
+	rect := numbers(ctx, annotation["Rect"])
+	boundingBox := numbers(ctx, appearance.Dict["BBox"])
+	if len(rect) != 4 || len(boundingBox) != 4 {
+		return "", false
+	}
+
+	matrix := numbers(ctx, appearance.Dict["Matrix"])
+	if len(matrix) != 6 {
+		matrix = []float64{1, 0, 0, 1, 0, 0}
+	}
+
+	// The bounding box transformed by the appearance matrix
+	minX, minY, maxX, maxY := 0.0, 0.0, 0.0, 0.0
+	for index, corner := range [][2]float64{{boundingBox[0], boundingBox[1]}, {boundingBox[2], boundingBox[1]}, {boundingBox[0], boundingBox[3]}, {boundingBox[2], boundingBox[3]}} {
+		x := matrix[0]*corner[0] + matrix[2]*corner[1] + matrix[4]
+		y := matrix[1]*corner[0] + matrix[3]*corner[1] + matrix[5]
+		if index == 0 {
+			minX, minY, maxX, maxY = x, y, x, y
+			continue
+		}
+		minX, minY, maxX, maxY = min(minX, x), min(minY, y), max(maxX, x), max(maxY, y)
+	}
+
+	if maxX == minX || maxY == minY {
+		return "", false
+	}
+
+	rectMinX, rectMinY := min(rect[0], rect[2]), min(rect[1], rect[3])
+	scaleX := (max(rect[0], rect[2]) - rectMinX) / (maxX - minX)
+	scaleY := (max(rect[1], rect[3]) - rectMinY) / (maxY - minY)
+
+	return fmt.Sprintf("%.4f 0 0 %.4f %.4f %.4f", scaleX, scaleY, rectMinX-minX*scaleX, rectMinY-minY*scaleY), true
+}
+
+func numbers(ctx *model.Context, object types.Object) []float64 {
+	array, err := ctx.DereferenceArray(object)
+	if err != nil {
+		return nil
+	}
+
+	var values []float64
+	for _, entry := range array {
+		value, err := ctx.DereferenceNumber(entry)
+		if err != nil {
+			return nil
+		}
+		values = append(values, value)
+	}
+
+	return values
+}
+
+func newContentStream(ctx *model.Context, content []byte) (*types.IndirectRef, error) {
+	stream, err := ctx.NewStreamDictForBuf(content)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := stream.Encode(); err != nil {
+		return nil, err
+	}
+
+	return ctx.IndRefForNewObject(*stream)
+}
@@ -0,0 +1,139 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/pdfcpu/pdfcpu/pkg/api"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/model"
+)
+
+// newTestFormPdf creates a single page pdf with a "name" text field and an "agree" checkbox
+func newTestFormPdf(t *testing.T) []byte {
+	t.Helper()
+
+	definition := `{"paper": "A4P", "origin": "LowerLeft", "pages": {"1": {"content": {
+		"text": [{"value": "Application", "pos": [100, 760], "font": {"name": "Helvetica", "size": 24}}],
+		"textfield": [{"id": "name", "value": "Jackie", "pos": [100, 700], "width": 150, "font": {"name": "Helvetica", "size": 12}}],
+		"checkbox": [{"id": "agree", "value": false, "pos": [100, 650], "width": 12}]
+	}}}}`
+
+	var output bytes.Buffer
+	if err := api.Create(nil, strings.NewReader(definition), &output, nil); err != nil {
+		t.Fatalf("unable to create test form: %s", err)
+	}
+
+	return output.Bytes()
+}
+
+func TestFillPdfForm(t *testing.T) {
+	filled, err := fillPdfForm(newTestFormPdf(t), map[string]any{"name": "Sam", "agree": "true"}, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	fields, err := getPdfFormFields(filled)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	values := make(map[string]any)
+	for _, field := range fields {
+		values[field.Name] = field.Value
+	}
+
+	if values["name"] != "Sam" || values["agree"] != true {
+		t.Errorf("fields = %v, want name Sam and agree checked", values)
+	}
+}
+
+func TestFillPdfFormErrors(t *testing.T) {
+	tests := map[string]map[string]any{
+		"unknown field":        {"surname": "Doe"},
+		"invalid boolean":      {"agree": "perhaps"},
+		"object as text value": {"name": map[string]any{"first": "Sam"}},
+	}
+
+	for name, values := range tests {
+		t.Run(name, func(t *testing.T) {
+			if _, err := fillPdfForm(newTestFormPdf(t), values, false); err == nil {
+				t.Error("expected an error")
+			}
+		})
+	}
+}
+
+func TestFlattenPdfForm(t *testing.T) {
+	flattened, err := fillPdfForm(newTestFormPdf(t), map[string]any{"name": "Sam"}, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := getPdfFormFields(flattened); err == nil {
+		t.Error("the flattened pdf still has form fields")
+	}
+
+	ctx, err := api.ReadValidateAndOptimize(bytes.NewReader(flattened), model.NewDefaultConfiguration())
+	if err != nil {
+		t.Fatalf("the flattened pdf does not validate: %s", err)
+	}
+
+	pageDict, _, inheritedAttributes, err := ctx.PageDict(1, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	annotations, err := ctx.DereferenceArray(pageDict["Annots"])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, annotationObject := range annotations {
+		annotation, err := ctx.DereferenceDict(annotationObject)
+		if err == nil && annotation.NameEntry("Subtype") != nil && *annotation.NameEntry("Subtype") == "Widget" {
+			t.Error("a widget annotation is left on the page")
+		}
+	}
+
+	xObjects, err := ctx.DereferenceDict(inheritedAttributes.Resources["XObject"])
+	if err != nil || xObjects == nil {
+		t.Fatalf("the page has no xobjects: %v", err)
+	}
+
+	// Both widgets are drawn and the text field shows the filled value
+	var appearances []string
+	for name, appearanceObject := range xObjects {
+		if !strings.HasPrefix(name, "FlattenedField") {
+			continue
+		}
+
+		appearance, _, err := ctx.DereferenceStreamDict(appearanceObject)
+		if err != nil || appearance == nil {
+			t.Fatalf("unable to read %s: %v", name, err)
+		}
+
+		if err := appearance.Decode(); err != nil {
+			t.Fatal(err)
+		}
+		appearances = append(appearances, string(appearance.Content))
+	}
+
+	if len(appearances) != 2 {
+		t.Fatalf("%d fields drawn into the page, want 2", len(appearances))
+	}
+
+	if !strings.Contains(strings.Join(appearances, "\n"), "(Sam)") {
+		t.Error("the filled value is not drawn")
+	}
+
+	content, err := ctx.PageContent(pageDict, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The original content is wrapped so its graphics state can not move the fields
+	if !bytes.HasPrefix(content, []byte("q\n")) || !bytes.Contains(content, []byte("/FlattenedField1 Do")) {
+		t.Errorf("unexpected page content:\n%s", content)
+	}
+}
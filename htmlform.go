@@ -0,0 +1,621 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/pdfcpu/pdfcpu/pkg/api"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/model"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/types"
+)
+
+// Every form control is covered by a link to formControlLinkPrefix<index> before printing, Chrome turns these into uri
+// links with the position of the control on its page
+const formControlLinkPrefix = "https://form.invalid/field/"
+
+var formControlLinkPattern = regexp.MustCompile(`^` + regexp.QuoteMeta(formControlLinkPrefix) + `(\d+)$`)
+var pdfNamePattern = regexp.MustCompile(`[^A-Za-z0-9_.-]`)
+
+// Field flags from the pdf specification
+const (
+	fieldFlagReadOnly        = 1 << 0
+	fieldFlagRequired        = 1 << 1
+	fieldFlagMultiline       = 1 << 12
+	fieldFlagPassword        = 1 << 13
+	fieldFlagNoToggleToOff   = 1 << 14
+	fieldFlagRadio           = 1 << 15
+	fieldFlagCombo           = 1 << 17
+	fieldFlagMultiSelect     = 1 << 21
+	annotationFlagPrint      = 1 << 2
+	defaultFieldAppearance   = "/Helv 0 Tf 0 g"
+	checkBoxOnState          = "Yes"
+	zapfDingbatsCheck        = "4"
+	zapfDingbatsCheckWidth   = 0.846
+	zapfDingbatsCircle       = "l"
+	zapfDingbatsCircleWidth  = 0.791
+	signaturePlaceholderAttr = "data-signature-field"
+)
+
+// collectFormControlsScript records the form controls of the page and covers each one with a placeholder link. The
+// rendered values are hidden, the pdf fields draw them instead.
+var collectFormControlsScript = `(function (prefix, signatureAttribute) {
+	var controls = [];
+	var ignoredTypes = ['hidden', 'submit', 'reset', 'button', 'image', 'file', 'range', 'color'];
+	var elements = document.querySelectorAll('input, select, textarea, [' + signatureAttribute + ']');
+
+	elements.forEach(function (element) {
+		var tag = element.tagName.toLowerCase();
+		var control = {name: element.getAttribute('name') || element.id || '', type: 'text', value: '', values: [], checked: false,
+			multiline: false, password: false, maxLength: 0, multiple: false, listBox: false, options: [],
+			readOnly: !!(element.readOnly || element.disabled), required: !!element.required};
+
+		if (element.hasAttribute(signatureAttribute)) {
+			control.type = 'signature';
+			control.name = element.getAttribute(signatureAttribute) || control.name;
+		} else if (tag === 'textarea') {
+			control.multiline = true;
+			control.value = element.value;
+		} else if (tag === 'select') {
+			control.type = 'choice';
+			control.multiple = element.multiple;
+			control.listBox = element.multiple || element.size > 1;
+			control.options = Array.from(element.options).map(function (option) {
+				return {value: option.value, label: option.text};
+			});
+			control.values = Array.from(element.selectedOptions).map(function (option) {
+				return option.value;
+			});
+		} else {
+			var type = (element.getAttribute('type') || 'text').toLowerCase();
+			if (ignoredTypes.indexOf(type) >= 0) {
+				return;
+			}
+
+			if (type === 'checkbox' || type === 'radio') {
+				control.type = type;
+				control.checked = element.checked;
+				control.value = element.value;
+			} else {
+				control.password = type === 'password';
+				control.value = element.value;
+			}
+		}
+
+		if (tag !== 'select' && element.maxLength > 0) {
+			control.maxLength = element.maxLength;
+		}
+
+		var box = element.getBoundingClientRect();
+		if (box.width === 0 || box.height === 0) {
+			return;
+		}
+
+		if (control.type === 'checkbox' || control.type === 'radio') {
+			element.checked = false;
+		} else if (control.type !== 'signature') {
+			element.style.setProperty('color', 'transparent', 'important');
+			element.style.setProperty('-webkit-text-fill-color', 'transparent', 'important');
+			element.removeAttribute('placeholder');
+		}
+
+		var link = document.createElement('a');
+		link.href = prefix + controls.length;
+		link.style.cssText = 'position: absolute; display: block; margin: 0; padding: 0; border: 0;';
+
+		if (tag === 'input' || tag === 'select' || tag === 'textarea') {
+			// These can not have children, wrap them so the link can be positioned over them
+			var style = getComputedStyle(element);
+			var wrapper = document.createElement('span');
+			wrapper.style.cssText = 'position: relative; margin: 0; padding: 0; border: 0;';
+			wrapper.style.display = style.display === 'inline' ? 'inline-block' : style.display;
+			element.parentNode.insertBefore(wrapper, element);
+			wrapper.appendChild(element);
+			wrapper.appendChild(link);
+			link.style.left = element.offsetLeft + 'px';
+			link.style.top = element.offsetTop + 'px';
+			link.style.width = element.offsetWidth + 'px';
+			link.style.height = element.offsetHeight + 'px';
+		} else {
+			if (getComputedStyle(element).position === 'static') {
+				element.style.position = 'relative';
+			}
+			element.appendChild(link);
+			link.style.left = '0';
+			link.style.top = '0';
+			link.style.width = '100%';
+			link.style.height = '100%';
+		}
+
+		controls.push(control);
+	});
+
+	return controls;
+})(%s, %s)`
+
+type htmlFormControl struct {
+	Name      string           `json:"name"`
+	Type      string           `json:"type"`
+	Value     string           `json:"value"`
+	Values    []string         `json:"values"`
+	Checked   bool             `json:"checked"`
+	Multiline bool             `json:"multiline"`
+	Password  bool             `json:"password"`
+	MaxLength int              `json:"maxLength"`
+	Multiple  bool             `json:"multiple"`
+	ListBox   bool             `json:"listBox"`
+	Options   []htmlFormOption `json:"options"`
+	ReadOnly  bool             `json:"readOnly"`
+	Required  bool             `json:"required"`
+}
+
+type htmlFormOption struct {
+	Value string `json:"value"`
+	Label string `json:"label"`
+}
+
+// formControlWidget is where a form control ended up in the printed pdf
+type formControlWidget struct {
+	page types.IndirectRef
+	rect []float64
+}
+
+func getCollectFormControlsScript() string {
+	prefix, _ := json.Marshal(formControlLinkPrefix)
+	attribute, _ := json.Marshal(signaturePlaceholderAttr)
+
+	return fmt.Sprintf(collectFormControlsScript, prefix, attribute)
+}
+
+// addFormFields replaces the placeholder links Chrome printed for controls with AcroForm fields
+func addFormFields(pdfData []byte, controls []htmlFormControl) ([]byte, error) {
+	if len(controls) == 0 {
+		return pdfData, nil
+	}
+
+	conf := model.NewDefaultConfiguration()
+	ctx, err := api.ReadValidateAndOptimize(bytes.NewReader(pdfData), conf)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read pdf: %w", err)
+	}
+
+	widgets, err := removeFormControlLinks(ctx, len(controls))
+	if err != nil {
+		return nil, err
+	}
+
+	if len(widgets) == 0 {
+		return pdfData, nil
+	}
+
+	builder, err := newFormFieldBuilder(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	for index, control := range controls {
+		widget, found := widgets[index]
+		if !found {
+			continue
+		}
+
+		if err := builder.add(control, widget); err != nil {
+			return nil, fmt.Errorf("unable to add form field %q: %w", control.Name, err)
+		}
+	}
+
+	catalog, err := ctx.Catalog()
+	if err != nil {
+		return nil, err
+	}
+
+	acroForm := types.Dict{
+		"Fields":          builder.fields,
+		"NeedAppearances": types.Boolean(true),
+		"DA":              types.StringLiteral(defaultFieldAppearance),
+		"DR":              types.Dict{"Font": builder.fonts},
+	}
+	if builder.signatures {
+		acroForm["SigFlags"] = types.Integer(1)
+	}
+	catalog["AcroForm"] = acroForm
+
+	var output bytes.Buffer
+	if err := api.Write(ctx, &output, conf); err != nil {
+		return nil, fmt.Errorf("unable to write pdf: %w", err)
+	}
+
+	return output.Bytes(), nil
+}
+
+// removeFormControlLinks removes the placeholder links and returns the first position of every control
+func removeFormControlLinks(ctx *model.Context, controlCount int) (map[int]formControlWidget, error) {
+	widgets := make(map[int]formControlWidget)
+
+	for pageNumber := 1; pageNumber <= ctx.PageCount; pageNumber++ {
+		pageDict, pageRef, _, err := ctx.PageDict(pageNumber, false)
+		if err != nil {
+			return nil, err
+		}
+
+		annotations, err := ctx.DereferenceArray(pageDict["Annots"])
+		if err != nil {
+			return nil, err
+		}
+
+		var keptAnnotations types.Array
+		for _, annotationObject := range annotations {
+			index, rect := formControlLink(ctx, annotationObject)
+			if index < 0 || index >= controlCount {
+				keptAnnotations = append(keptAnnotations, annotationObject)
+				continue
+			}
+
+			// A control split over two pages gets its field on the first
+			if _, found := widgets[index]; !found && len(rect) == 4 {
+				widgets[index] = formControlWidget{page: *pageRef, rect: rect}
+			}
+		}
+
+		if len(keptAnnotations) != len(annotations) {
+			if len(keptAnnotations) > 0 {
+				pageDict["Annots"] = keptAnnotations
+			} else {
+				pageDict.Delete("Annots")
+			}
+		}
+	}
+
+	return widgets, nil
+}
+
+// formControlLink returns the control index and rectangle of a placeholder link, the index is -1 for anything else
+func formControlLink(ctx *model.Context, annotationObject types.Object) (int, []float64) {
+	annotation, err := ctx.DereferenceDict(annotationObject)
+	if err != nil || annotation == nil {
+		return -1, nil
+	}
+
+	action, err := ctx.DereferenceDict(annotation["A"])
+	if err != nil || action == nil {
+		return -1, nil
+	}
+
+	uri, err := ctx.DereferenceStringOrHexLiteral(action["URI"], model.V10, nil)
+	if err != nil {
+		return -1, nil
+	}
+
+	matches := formControlLinkPattern.FindStringSubmatch(uri)
+	if matches == nil {
+		return -1, nil
+	}
+
+	index, _ := strconv.Atoi(matches[1])
+	return index, numbers(ctx, annotation["Rect"])
+}
+
+type formFieldBuilder struct {
+	ctx        *model.Context
+	fields     types.Array
+	fonts      types.Dict
+	names      map[string]int
+	radios     map[string]radioGroup
+	signatures bool
+}
+
+// radioGroup is the parent field shared by the radio buttons with the same name
+type radioGroup struct {
+	field types.Dict
+	ref   types.IndirectRef
+}
+
+func newFormFieldBuilder(ctx *model.Context) (*formFieldBuilder, error) {
+	helvetica, err := ctx.IndRefForNewObject(types.Dict{
+		"Type":     types.Name("Font"),
+		"Subtype":  types.Name("Type1"),
+		"BaseFont": types.Name("Helvetica"),
+		"Encoding": types.Name("WinAnsiEncoding"),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	zapfDingbats, err := ctx.IndRefForNewObject(types.Dict{
+		"Type":     types.Name("Font"),
+		"Subtype":  types.Name("Type1"),
+		"BaseFont": types.Name("ZapfDingbats"),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &formFieldBuilder{
+		ctx:    ctx,
+		fonts:  types.Dict{"Helv": *helvetica, "ZaDb": *zapfDingbats},
+		names:  make(map[string]int),
+		radios: make(map[string]radioGroup),
+	}, nil
+}
+
+// uniqueName keeps field names unique, controls that share a name would otherwise share a value
+func (builder *formFieldBuilder) uniqueName(name string) string {
+	if name == "" {
+		name = fmt.Sprintf("field%d", len(builder.names)+1)
+	}
+
+	builder.names[name]++
+	if count := builder.names[name]; count > 1 {
+		return fmt.Sprintf("%s_%d", name, count)
+	}
+
+	return name
+}
+
+func (builder *formFieldBuilder) add(control htmlFormControl, widget formControlWidget) error {
+	rect := widget.rect
+	width, height := rect[2]-rect[0], rect[3]-rect[1]
+
+	annotation := types.Dict{
+		"Type":    types.Name("Annot"),
+		"Subtype": types.Name("Widget"),
+		"Rect":    types.NewNumberArray(rect...),
+		"P":       widget.page,
+		"F":       types.Integer(annotationFlagPrint),
+	}
+
+	flags := 0
+	if control.ReadOnly {
+		flags |= fieldFlagReadOnly
+	}
+	if control.Required {
+		flags |= fieldFlagRequired
+	}
+
+	switch control.Type {
+	case "radio":
+		return builder.addRadio(control, annotation, flags, width, height)
+	case "checkbox":
+		state := "Off"
+		if control.Checked {
+			state = checkBoxOnState
+		}
+
+		on, err := builder.appearance(width, height, symbolAppearance(zapfDingbatsCheck, zapfDingbatsCheckWidth, width, height))
+		if err != nil {
+			return err
+		}
+		off, err := builder.appearance(width, height, "")
+		if err != nil {
+			return err
+		}
+
+		annotation["FT"] = types.Name("Btn")
+		annotation["V"] = types.Name(state)
+		annotation["AS"] = types.Name(state)
+		annotation["MK"] = types.Dict{"CA": types.StringLiteral(zapfDingbatsCheck)}
+		annotation["AP"] = types.Dict{"N": types.Dict{checkBoxOnState: *on, "Off": *off}}
+	case "choice":
+		// Options are stored by their label, which is what viewers show and what /pdf/form fills choices with
+		options := types.Array{}
+		labels := make(map[string]string)
+		for _, option := range control.Options {
+			labels[option.Value] = option.Label
+			options = append(options, pdfTextStringLiteral(option.Label))
+		}
+
+		if control.ListBox {
+			if control.Multiple {
+				flags |= fieldFlagMultiSelect
+			}
+		} else {
+			flags |= fieldFlagCombo
+		}
+
+		var selectedLabels []string
+		for _, value := range control.Values {
+			selectedLabels = append(selectedLabels, labels[value])
+		}
+
+		if len(selectedLabels) == 1 {
+			annotation["V"] = pdfTextStringLiteral(selectedLabels[0])
+		} else if len(selectedLabels) > 1 {
+			values := types.Array{}
+			for _, label := range selectedLabels {
+				values = append(values, pdfTextStringLiteral(label))
+			}
+			annotation["V"] = values
+		}
+
+		appearance, err := builder.appearance(width, height, textAppearance(selectedLabels, control.ListBox, width, height))
+		if err != nil {
+			return err
+		}
+
+		annotation["FT"] = types.Name("Ch")
+		annotation["Opt"] = options
+		annotation["DA"] = types.StringLiteral(defaultFieldAppearance)
+		annotation["AP"] = types.Dict{"N": *appearance}
+	case "signature":
+		builder.signatures = true
+		annotation["FT"] = types.Name("Sig")
+	default:
+		if control.Multiline {
+			flags |= fieldFlagMultiline
+		}
+
+		shown := control.Value
+		if control.Password {
+			flags |= fieldFlagPassword
+			shown = strings.Repeat("*", len([]rune(control.Value)))
+		}
+
+		if control.MaxLength > 0 {
+			annotation["MaxLen"] = types.Integer(control.MaxLength)
+		}
+
+		appearance, err := builder.appearance(width, height, textAppearance(strings.Split(shown, "\n"), control.Multiline, width, height))
+		if err != nil {
+			return err
+		}
+
+		annotation["FT"] = types.Name("Tx")
+		annotation["V"] = pdfTextStringLiteral(control.Value)
+		annotation["DA"] = types.StringLiteral(defaultFieldAppearance)
+		annotation["AP"] = types.Dict{"N": *appearance}
+	}
+
+	annotation["T"] = pdfTextStringLiteral(builder.uniqueName(control.Name))
+	if flags != 0 {
+		annotation["Ff"] = types.Integer(flags)
+	}
+
+	annotationRef, err := builder.ctx.IndRefForNewObject(annotation)
+	if err != nil {
+		return err
+	}
+
+	builder.fields = append(builder.fields, *annotationRef)
+
+	return builder.addToPage(widget.page, *annotationRef)
+}
+
+// addRadio adds the widget for a radio button to the field of its group, creating the field for the first button
+func (builder *formFieldBuilder) addRadio(control htmlFormControl, annotation types.Dict, flags int, width float64, height float64) error {
+	state := pdfNamePattern.ReplaceAllString(control.Value, "_")
+	if state == "" || state == "Off" {
+		state = fmt.Sprintf("Choice%d", len(builder.fields)+1)
+	}
+
+	group, found := builder.radios[control.Name]
+	if !found {
+		field := types.Dict{
+			"FT":   types.Name("Btn"),
+			"T":    pdfTextStringLiteral(builder.uniqueName(control.Name)),
+			"Ff":   types.Integer(flags | fieldFlagRadio | fieldFlagNoToggleToOff),
+			"V":    types.Name("Off"),
+			"Kids": types.Array{},
+		}
+
+		fieldRef, err := builder.ctx.IndRefForNewObject(field)
+		if err != nil {
+			return err
+		}
+
+		group = radioGroup{field: field, ref: *fieldRef}
+		builder.radios[control.Name] = group
+		builder.fields = append(builder.fields, *fieldRef)
+	}
+
+	appearanceState := "Off"
+	if control.Checked {
+		appearanceState = state
+		group.field["V"] = types.Name(state)
+	}
+
+	on, err := builder.appearance(width, height, symbolAppearance(zapfDingbatsCircle, zapfDingbatsCircleWidth, width, height))
+	if err != nil {
+		return err
+	}
+	off, err := builder.appearance(width, height, "")
+	if err != nil {
+		return err
+	}
+
+	annotation["Parent"] = group.ref
+	annotation["AS"] = types.Name(appearanceState)
+	annotation["MK"] = types.Dict{"CA": types.StringLiteral(zapfDingbatsCircle)}
+	annotation["AP"] = types.Dict{"N": types.Dict{state: *on, "Off": *off}}
+
+	annotationRef, err := builder.ctx.IndRefForNewObject(annotation)
+	if err != nil {
+		return err
+	}
+
+	group.field["Kids"] = append(group.field["Kids"].(types.Array), *annotationRef)
+
+	return builder.addToPage(annotation["P"].(types.IndirectRef), *annotationRef)
+}
+
+func (builder *formFieldBuilder) addToPage(pageRef types.IndirectRef, annotationRef types.IndirectRef) error {
+	pageDict, err := builder.ctx.DereferenceDict(pageRef)
+	if err != nil {
+		return err
+	}
+
+	annotations, err := builder.ctx.DereferenceArray(pageDict["Annots"])
+	if err != nil {
+		return err
+	}
+
+	pageDict["Annots"] = append(annotations, annotationRef)
+
+	return nil
+}
+
+// appearance creates a form xobject with the field fonts available
+func (builder *formFieldBuilder) appearance(width float64, height float64, content string) (*types.IndirectRef, error) {
+	stream, err := builder.ctx.NewStreamDictForBuf([]byte(content))
+	if err != nil {
+		return nil, err
+	}
+
+	stream.InsertName("Type", "XObject")
+	stream.InsertName("Subtype", "Form")
+	stream.Insert("BBox", types.NewNumberArray(0, 0, width, height))
+	stream.Insert("Resources", types.Dict{"Font": builder.fonts})
+
+	if err := stream.Encode(); err != nil {
+		return nil, err
+	}
+
+	return builder.ctx.IndRefForNewObject(*stream)
+}
+
+// textAppearance draws the first line of text centered vertically, or every line from the top when multiline is set
+func textAppearance(lines []string, multiline bool, width float64, height float64) string {
+	if len(lines) == 0 || (len(lines) == 1 && lines[0] == "") {
+		return ""
+	}
+
+	fontSize := min(12, max(4, (height-4)*0.75))
+	if multiline {
+		fontSize = min(10, max(4, height-4))
+	}
+
+	var content strings.Builder
+	fmt.Fprintf(&content, "/Tx BMC q 1 1 %.2f %.2f re W n BT /Helv %.2f Tf 0 g %.2f TL ", max(0, width-2), max(0, height-2), fontSize, fontSize*1.15)
+	if multiline {
+		fmt.Fprintf(&content, "2 %.2f Td\n", height-2-fontSize)
+	} else {
+		fmt.Fprintf(&content, "2 %.2f Td\n", (height-fontSize*0.7)/2)
+		lines = lines[:1]
+	}
+
+	for index, line := range lines {
+		if index > 0 {
+			content.WriteString("T* ")
+		}
+		escaped, _ := types.Escape(types.UTF8ToCP1252(line))
+		fmt.Fprintf(&content, "(%s) Tj\n", *escaped)
+	}
+	content.WriteString("ET Q EMC")
+
+	return content.String()
+}
+
+// symbolAppearance draws a ZapfDingbats symbol centered in the widget
+func symbolAppearance(symbol string, symbolWidth float64, width float64, height float64) string {
+	fontSize := min(width, height) * 0.8
+
+	return fmt.Sprintf("q 0 g BT /ZaDb %.2f Tf %.2f %.2f Td (%s) Tj ET Q", fontSize, (width-fontSize*symbolWidth)/2, (height-fontSize*0.7)/2, symbol)
+}
+
+// pdfTextStringLiteral is pdfTextString for strings stored in pdf objects
+func pdfTextStringLiteral(s string) types.StringLiteral {
+	literal := pdfTextString(s)
+	return types.StringLiteral(literal[1 : len(literal)-1])
+}
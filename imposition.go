@@ -0,0 +1,178 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/pdfcpu/pdfcpu/pkg/api"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/model"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/types"
+)
+
+const (
+	ImpositionNUp     string = "nup"
+	ImpositionBooklet string = "booklet"
+)
+
+var impositionOrders = map[string]string{
+	"rightDown": "rd",
+	"downRight": "dr",
+	"leftDown":  "ld",
+	"downLeft":  "dl",
+}
+
+type PdfImposition struct {
+	Layout          string    `json:"layout" form:"impositionLayout"`
+	PagesPerSheet   int       `json:"pagesPerSheet" form:"impositionPagesPerSheet"`
+	Order           string    `json:"order" form:"impositionOrder"`
+	Gutter          float64   `json:"gutter" form:"impositionGutter"`
+	Border          bool      `json:"border" form:"impositionBorder"`
+	SheetSize       string    `json:"sheetSize" form:"impositionSheetSize"`
+	SheetDimensions []float64 `json:"sheetDimensions" form:"impositionSheetDimensions"`
+	Binding         string    `json:"binding" form:"impositionBinding"`
+	Guides          bool      `json:"guides" form:"impositionGuides"`
+}
+
+func getImpositionConfiguration(imposition *PdfImposition) (*model.NUp, error) {
+	if imposition.Gutter < 0 {
+		return nil, errors.New("gutter can not be negative")
+	}
+
+	if imposition.SheetSize != "" && len(imposition.SheetDimensions) > 0 {
+		return nil, errors.New("only one of sheetSize or sheetDimensions may be provided")
+	}
+
+	var nup *model.NUp
+	var err error
+
+	switch imposition.Layout {
+	case ImpositionNUp:
+		pagesPerSheet := imposition.PagesPerSheet
+		if pagesPerSheet == 0 {
+			pagesPerSheet = 2
+		}
+
+		order := imposition.Order
+		if order == "" {
+			order = "rightDown"
+		}
+
+		orientation, found := impositionOrders[order]
+		if !found {
+			return nil, fmt.Errorf("unknown imposition order %q, expected rightDown, downRight, leftDown or downLeft", order)
+		}
+
+		nup, err = api.PDFNUpConfig(pagesPerSheet, "orientation:"+orientation, nil)
+	case ImpositionBooklet:
+		pagesPerSheet := imposition.PagesPerSheet
+		if pagesPerSheet == 0 {
+			pagesPerSheet = 2
+		}
+
+		binding := imposition.Binding
+		if binding == "" {
+			binding = "long"
+		}
+
+		if binding != "long" && binding != "short" {
+			return nil, fmt.Errorf("unknown booklet binding %q, expected long or short", binding)
+		}
+
+		nup, err = api.PDFBookletConfig(pagesPerSheet, "binding:"+binding, nil)
+	default:
+		return nil, fmt.Errorf("unknown imposition layout %q, expected nup or booklet", imposition.Layout)
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("invalid imposition: %w", err)
+	}
+
+	if imposition.SheetSize != "" {
+		nup.PageDim, nup.PageSize, err = types.ParsePageFormat(imposition.SheetSize)
+		if err != nil {
+			return nil, fmt.Errorf("invalid sheetSize: %w", err)
+		}
+		nup.UserDim = true
+	}
+
+	// sheetDimensions are in inches like paperSize
+	if len(imposition.SheetDimensions) > 0 {
+		if len(imposition.SheetDimensions) != 2 || imposition.SheetDimensions[0] <= 0 || imposition.SheetDimensions[1] <= 0 {
+			return nil, errors.New("sheetDimensions must be a positive width and height in inches")
+		}
+		nup.PageDim = &types.Dim{Width: imposition.SheetDimensions[0] * 72, Height: imposition.SheetDimensions[1] * 72}
+		nup.PageSize = ""
+		nup.UserDim = true
+	}
+
+	// The margin surrounds every page so half of it ends up on each side of a gutter
+	nup.Margin = imposition.Gutter / 2
+	nup.Border = imposition.Border
+	nup.BookletGuides = imposition.Guides
+
+	return nup, nil
+}
+
+// imposePdf replaces the pages of pdfFile with the imposed sheets
+func imposePdf(pdfFile string, imposition *PdfImposition) error {
+	nup, err := getImpositionConfiguration(imposition)
+	if err != nil {
+		return err
+	}
+
+	pdfData, err := os.ReadFile(pdfFile)
+	if err != nil {
+		return fmt.Errorf("unable to read pdf for imposition: %w", err)
+	}
+
+	if imposition.Layout == ImpositionBooklet {
+		// Pad with blank pages so every sheet folds into complete signatures
+		pdfData, err = padPdfPages(pdfData, 4)
+		if err != nil {
+			return fmt.Errorf("unable to pad booklet: %w", err)
+		}
+	}
+
+	var output bytes.Buffer
+	if imposition.Layout == ImpositionBooklet {
+		err = api.Booklet(bytes.NewReader(pdfData), &output, nil, nil, nup, nil)
+	} else {
+		err = api.NUp(bytes.NewReader(pdfData), &output, nil, nil, nup, nil)
+	}
+
+	if err != nil {
+		return fmt.Errorf("unable to impose pdf: %w", err)
+	}
+
+	return os.WriteFile(pdfFile, output.Bytes(), 0640)
+}
+
+// padPdfPages appends blank pages until the page count is a multiple of multiple
+func padPdfPages(pdfData []byte, multiple int) ([]byte, error) {
+	conf := model.NewDefaultConfiguration()
+	ctx, err := api.ReadValidateAndOptimize(bytes.NewReader(pdfData), conf)
+	if err != nil {
+		return nil, err
+	}
+
+	missing := (multiple - ctx.PageCount%multiple) % multiple
+	if missing == 0 {
+		return pdfData, nil
+	}
+
+	for range missing {
+		// Blank pages take the size of the page they follow
+		if err := ctx.InsertBlankPages(types.IntSet{ctx.PageCount: true}, nil, false); err != nil {
+			return nil, err
+		}
+	}
+
+	var output bytes.Buffer
+	if err := api.Write(ctx, &output, conf); err != nil {
+		return nil, err
+	}
+
+	return output.Bytes(), nil
+}
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"bytes"
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/pdfcpu/pdfcpu/pkg/api"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/model"
+)
+
+func TestGetImpositionConfigurationErrors(t *testing.T) {
+	tests := map[string]PdfImposition{
+		"unknown layout":        {Layout: "poster"},
+		"negative gutter":       {Layout: ImpositionNUp, Gutter: -1},
+		"unknown order":         {Layout: ImpositionNUp, Order: "upLeft"},
+		"unknown binding":       {Layout: ImpositionBooklet, Binding: "spiral"},
+		"size and dimensions":   {Layout: ImpositionNUp, SheetSize: "A3", SheetDimensions: []float64{11, 17}},
+		"single dimension":      {Layout: ImpositionNUp, SheetDimensions: []float64{11}},
+		"negative dimension":    {Layout: ImpositionNUp, SheetDimensions: []float64{11, -17}},
+		"unknown sheet size":    {Layout: ImpositionNUp, SheetSize: "Napkin"},
+		"invalid pages per nup": {Layout: ImpositionNUp, PagesPerSheet: 5},
+	}
+
+	for name, imposition := range tests {
+		t.Run(name, func(t *testing.T) {
+			if _, err := getImpositionConfiguration(&imposition); err == nil {
+				t.Error("expected an error")
+			}
+		})
+	}
+}
+
+func TestGetImpositionConfiguration(t *testing.T) {
+	nup, err := getImpositionConfiguration(&PdfImposition{Layout: ImpositionNUp, Gutter: 10, SheetDimensions: []float64{11, 17}, Border: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if nup.PageDim.Width != 792 || nup.PageDim.Height != 1224 || !nup.UserDim {
+		t.Errorf("sheet = %vx%v, want 792x1224 points", nup.PageDim.Width, nup.PageDim.Height)
+	}
+
+	if nup.Margin != 5 {
+		t.Errorf("margin = %v, want half the gutter", nup.Margin)
+	}
+
+	if nup.Grid.Width*nup.Grid.Height != 2 || !nup.Border {
+		t.Errorf("grid = %v, want 2 pages per sheet with borders", nup.Grid)
+	}
+}
+
+func TestPadPdfPages(t *testing.T) {
+	tests := map[int]int{1: 4, 4: 4, 5: 8}
+
+	for pages, want := range tests {
+		padded, err := padPdfPages(newTestPdf(t, pages), 4)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if count := testPageCount(t, padded); count != want {
+			t.Errorf("%d pages padded to %d, want %d", pages, count, want)
+		}
+	}
+}
+
+func TestImposePdf(t *testing.T) {
+	tests := []struct {
+		name       string
+		pages      int
+		imposition PdfImposition
+		sheets     int
+	}{
+		{name: "2-up", pages: 5, imposition: PdfImposition{Layout: ImpositionNUp}, sheets: 3},
+		{name: "4-up", pages: 5, imposition: PdfImposition{Layout: ImpositionNUp, PagesPerSheet: 4, Order: "downRight"}, sheets: 2},
+		{name: "booklet", pages: 5, imposition: PdfImposition{Layout: ImpositionBooklet}, sheets: 4},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			pdfFile := filepath.Join(t.TempDir(), "imposed.pdf")
+			if err := os.WriteFile(pdfFile, newTestPdf(t, test.pages), 0640); err != nil {
+				t.Fatal(err)
+			}
+
+			if err := imposePdf(pdfFile, &test.imposition); err != nil {
+				t.Fatal(err)
+			}
+
+			imposed, err := os.ReadFile(pdfFile)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if count := testPageCount(t, imposed); count != test.sheets {
+				t.Errorf("%d sheets, want %d", count, test.sheets)
+			}
+
+			dimensions, err := api.PageDims(bytes.NewReader(imposed), nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			// Without a sheet size the sheets keep the A4 size of the pages
+			if math.Round(dimensions[0].Width) != 595 || math.Round(dimensions[0].Height) != 842 {
+				t.Errorf("sheet is %vx%v points, want A4", dimensions[0].Width, dimensions[0].Height)
+			}
+		})
+	}
+}
+
+func testPageCount(t *testing.T, pdf []byte) int {
+	t.Helper()
+
+	ctx, err := api.ReadValidateAndOptimize(bytes.NewReader(pdf), model.NewDefaultConfiguration())
+	if err != nil {
+		t.Fatalf("unable to read pdf: %s", err)
+	}
+
+	return ctx.PageCount
+}
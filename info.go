@@ -0,0 +1,121 @@
+package main
+
+import (
+	"os"
+
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu"
+)
+
+type PdfInfoRequest struct {
+	PdfSource
+}
+
+// PdfInfo describes a pdf document
+type PdfInfo struct {
+	Pages      int           `json:"pages"`
+	PageSizes  []PdfPageSize `json:"pageSizes"`
+	Version    string        `json:"version"`
+	Encrypted  bool          `json:"encrypted"`
+	Tagged     bool          `json:"tagged"`
+	Linearized bool          `json:"linearized"`
+	Form       bool          `json:"form"`
+	Signed     bool          `json:"signed"`
+	Metadata   PdfMetadata   `json:"metadata"`
+	Fonts      []PdfFont     `json:"fonts"`
+}
+
+// PdfPageSize is the visible area of a page in points, before the page is rotated
+type PdfPageSize struct {
+	Page     int     `json:"page"`
+	Width    float64 `json:"width"`
+	Height   float64 `json:"height"`
+	Rotation int     `json:"rotation"`
+}
+
+type PdfMetadata struct {
+	Title            string            `json:"title"`
+	Author           string            `json:"author"`
+	Subject          string            `json:"subject"`
+	Keywords         []string          `json:"keywords"`
+	Creator          string            `json:"creator"`
+	Producer         string            `json:"producer"`
+	CreationDate     string            `json:"creationDate"`
+	ModificationDate string            `json:"modificationDate"`
+	Properties       map[string]string `json:"properties"`
+}
+
+type PdfFont struct {
+	Name     string `json:"name"`
+	Type     string `json:"type"`
+	Encoding string `json:"encoding"`
+	Embedded bool   `json:"embedded"`
+	// Subset fonts only embed the glyphs the document uses
+	Subset bool `json:"subset"`
+}
+
+// newPdfInfo converts the information pdfcpu collects
+func newPdfInfo(info *pdfcpu.PDFInfo) *PdfInfo {
+	pdfInfo := &PdfInfo{
+		Pages:      info.PageCount,
+		PageSizes:  make([]PdfPageSize, len(info.PageBoundaries)),
+		Version:    info.Version,
+		Encrypted:  info.Encrypted,
+		Tagged:     info.Tagged,
+		Linearized: info.Linearized,
+		Form:       info.Form,
+		Signed:     info.Signatures,
+		Metadata: PdfMetadata{
+			Title:            info.Title,
+			Author:           info.Author,
+			Subject:          info.Subject,
+			Keywords:         info.Keywords,
+			Creator:          info.Creator,
+			Producer:         info.Producer,
+			CreationDate:     info.CreationDate,
+			ModificationDate: info.ModificationDate,
+			Properties:       info.Properties,
+		},
+		Fonts: make([]PdfFont, len(info.Fonts)),
+	}
+
+	if pdfInfo.Metadata.Keywords == nil {
+		pdfInfo.Metadata.Keywords = []string{}
+	}
+
+	if pdfInfo.Metadata.Properties == nil {
+		pdfInfo.Metadata.Properties = map[string]string{}
+	}
+
+	for index, boundaries := range info.PageBoundaries {
+		cropBox := boundaries.CropBox()
+		pdfInfo.PageSizes[index] = PdfPageSize{
+			Page:     index + 1,
+			Width:    cropBox.Width(),
+			Height:   cropBox.Height(),
+			Rotation: boundaries.Rot,
+		}
+	}
+
+	for index, font := range info.Fonts {
+		pdfInfo.Fonts[index] = PdfFont{
+			Name:     font.Name,
+			Type:     font.Type,
+			Encoding: font.Encoding,
+			Embedded: font.Embedded,
+			Subset:   font.Prefix != "",
+		}
+	}
+
+	return pdfInfo
+}
+
+// getPdfDataInfo returns the information of a pdf that is not stored in a file
+func getPdfDataInfo(pdfData []byte, options *ServerOptions) (*PdfInfo, error) {
+	pdfFile, err := writePdfFile(pdfData, "*-info.pdf", options)
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(pdfFile)
+
+	return getPdfInfo(pdfFile, options)
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/chromedp/chromedp"
+)
+
+// isolateElementScript hides everything but the first visible element matching a selector. The ancestors of the
+// element stay in place so the page styles still apply, but lose their offsets so the element starts at the top of
+// the first page.
+const isolateElementScript = `(function (selector) {
+	var element = Array.from(document.querySelectorAll(selector)).find(function (candidate) {
+		return candidate.getClientRects().length > 0;
+	});
+	if (!element) {
+		return false;
+	}
+
+	var style = document.createElement('style');
+	style.textContent = '[data-pdf-hidden] { display: none !important; }' +
+		'[data-pdf-ancestor] { position: static !important; margin: 0 !important; padding: 0 !important;' +
+		' border: 0 !important; float: none !important; transform: none !important; height: auto !important;' +
+		' min-height: 0 !important; max-height: none !important; overflow: visible !important; }' +
+		'[data-pdf-selected] { position: static !important; margin: 0 !important; float: none !important;' +
+		' transform: none !important; }';
+	document.head.appendChild(style);
+
+	element.setAttribute('data-pdf-selected', '');
+	for (var node = element; node !== document.documentElement; node = node.parentElement) {
+		Array.from(node.parentElement.children).forEach(function (sibling) {
+			if (sibling !== node && sibling !== style) {
+				sibling.setAttribute('data-pdf-hidden', '');
+			}
+		});
+		node.parentElement.setAttribute('data-pdf-ancestor', '');
+	}
+	window.scrollTo(0, 0);
+
+	return true;
+})(%s)`
+
+// isolateElement leaves only the element matching selector on the page
+func isolateElement(selector string) chromedp.Action {
+	return chromedp.ActionFunc(func(ctx context.Context) error {
+		if selector == "" {
+			return errors.New("selector is empty")
+		}
+
+		encodedSelector, _ := json.Marshal(selector)
+
+		var found bool
+		if err := chromedp.Evaluate(fmt.Sprintf(isolateElementScript, encodedSelector), &found).Do(ctx); err != nil {
+			return fmt.Errorf("invalid selector %q: %w", selector, err)
+		}
+
+		if !found {
+			return fmt.Errorf("%w: %s", ErrNoElementMatched, selector)
+		}
+
+		return nil
+	})
+}
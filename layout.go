@@ -0,0 +1,242 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"html/template"
+	"os"
+	"slices"
+
+	"github.com/chromedp/cdproto/page"
+	"github.com/chromedp/chromedp"
+	"github.com/pdfcpu/pdfcpu/pkg/api"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/model"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/types"
+)
+
+// combinedPart is one of the pdfs merged into the combined pdf
+type combinedPart struct {
+	file  string
+	pages int
+}
+
+type SeparatorData struct {
+	Number int
+	Title  string
+}
+
+func getSeparatorTemplate(separator string) (*template.Template, error) {
+	separatorTemplate, err := template.New("separator").Parse(separator)
+	if err != nil {
+		return nil, fmt.Errorf("invalid separator template: %w", err)
+	}
+
+	return separatorTemplate, nil
+}
+
+// assembleCombinedPdf merges the components with the cover, table of contents and separator pages that were
+// requested, padding every part to an even number of pages in duplex mode
+func assembleCombinedPdf(pdfRequestParams *PdfRequest, components []string, renderedTitles []string, printOptions *page.PrintToPDFParams, opts []chromedp.ContextOption, serverOptions *ServerOptions) (*os.File, error) {
+	// Titles are only needed by the table of contents and separators
+	var titles []string
+	var err error
+	if pdfRequestParams.TableOfContents != nil || pdfRequestParams.Separator != nil {
+		var requestTitles []string
+		if pdfRequestParams.TableOfContents != nil {
+			requestTitles = pdfRequestParams.TableOfContents.Titles
+		}
+
+		titles, err = resolveComponentTitles(requestTitles, renderedTitles, components, serverOptions)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	var parts []combinedPart
+	if pdfRequestParams.Cover != nil {
+		coverData, err := buildDataEntry(*pdfRequestParams.Cover, printOptions, pdfRenderOptions{FormFields: pdfRequestParams.FormFields, MarkdownStylesheet: getMarkdownStylesheet(pdfRequestParams, serverOptions)}, opts, serverOptions, nil)
+		if err != nil {
+			return nil, fmt.Errorf("unable to generate cover page: %w", err)
+		}
+
+		coverPart, err := newCombinedPart(coverData, "*-cover.pdf", serverOptions)
+		if err != nil {
+			return nil, err
+		}
+		parts = append(parts, coverPart)
+	}
+
+	tocIndex := len(parts)
+
+	var separatorTemplate *template.Template
+	if pdfRequestParams.Separator != nil {
+		separatorTemplate, err = getSeparatorTemplate(*pdfRequestParams.Separator)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// componentParts holds the index of every component in parts, not counting the table of contents
+	componentParts := make([]int, len(components))
+	for index, component := range components {
+		if index > 0 && separatorTemplate != nil {
+			separatorPart, err := buildSeparator(separatorTemplate, SeparatorData{Number: index + 1, Title: titles[index]}, printOptions, opts, serverOptions)
+			if err != nil {
+				return nil, err
+			}
+			parts = append(parts, separatorPart)
+		}
+
+		pageCount, err := getPdfPageCount(component, serverOptions)
+		if err != nil {
+			return nil, err
+		}
+
+		componentParts[index] = len(parts)
+		parts = append(parts, combinedPart{file: component, pages: pageCount})
+	}
+
+	tocPages := 0
+	if pdfRequestParams.TableOfContents != nil {
+		startPages := func(tocPages int) []int {
+			starts, _ := layoutParts(slices.Insert(slices.Clone(parts), tocIndex, combinedPart{pages: tocPages}), pdfRequestParams.Duplex)
+
+			componentStarts := make([]int, len(componentParts))
+			for index, part := range componentParts {
+				componentStarts[index] = starts[part+1]
+			}
+
+			return componentStarts
+		}
+
+		var tocFile string
+		tocFile, tocPages, err = buildTableOfContents(pdfRequestParams.TableOfContents, titles, startPages, printOptions, opts, serverOptions)
+		if err != nil {
+			return nil, err
+		}
+
+		parts = slices.Insert(parts, tocIndex, combinedPart{file: tocFile, pages: tocPages})
+	}
+
+	starts, blankAfter := layoutParts(parts, pdfRequestParams.Duplex)
+
+	var inputFiles []string
+	for _, part := range parts {
+		inputFiles = append(inputFiles, part.file)
+	}
+
+	// Merge the PDF files
+	combinedFile, err := combinePdfs(inputFiles, serverOptions)
+	if err != nil {
+		return nil, fmt.Errorf("unable to combine component pdfs: %w", err)
+	}
+
+	if len(blankAfter) > 0 {
+		if err := insertBlankPages(combinedFile.Name(), blankAfter); err != nil {
+			return nil, fmt.Errorf("unable to pad combined pdf: %w", err)
+		}
+	}
+
+	if tocPages > 0 {
+		if err := linkTableOfContents(combinedFile.Name(), starts[tocIndex], tocPages); err != nil {
+			return nil, fmt.Errorf("unable to link table of contents: %w", err)
+		}
+	}
+
+	return combinedFile, nil
+}
+
+// layoutParts returns the page every part starts on in the combined pdf and the pages, numbered before any blank
+// pages are inserted, that a blank page has to follow. In duplex mode every part starts on an odd page.
+func layoutParts(parts []combinedPart, duplex bool) ([]int, []int) {
+	var starts []int
+	var blankAfter []int
+
+	startPage := 1
+	mergedPages := 0
+	for index, part := range parts {
+		starts = append(starts, startPage)
+		startPage += part.pages
+		mergedPages += part.pages
+
+		if duplex && part.pages%2 == 1 && index < len(parts)-1 {
+			blankAfter = append(blankAfter, mergedPages)
+			startPage++
+		}
+	}
+
+	return starts, blankAfter
+}
+
+func buildSeparator(separatorTemplate *template.Template, data SeparatorData, printOptions *page.PrintToPDFParams, opts []chromedp.ContextOption, serverOptions *ServerOptions) (combinedPart, error) {
+	var html bytes.Buffer
+	if err := separatorTemplate.Execute(&html, data); err != nil {
+		return combinedPart{}, fmt.Errorf("unable to render separator template: %w", err)
+	}
+
+	pdfData, err := renderPdf(html.String(), printOptions, pdfRenderOptions{}, opts, serverOptions, nil)
+	if err != nil {
+		return combinedPart{}, fmt.Errorf("unable to generate separator page: %w", err)
+	}
+
+	return newCombinedPart(pdfData, "*-separator.pdf", serverOptions)
+}
+
+func newCombinedPart(pdfData []byte, pattern string, serverOptions *ServerOptions) (combinedPart, error) {
+	pageCount, err := serverOptions.PdfEngine.PageCount(pdfData)
+	if err != nil {
+		return combinedPart{}, fmt.Errorf("unable to read page count: %w", err)
+	}
+
+	pdfFile, err := writePdfFile(pdfData, pattern, serverOptions)
+	if err != nil {
+		return combinedPart{}, err
+	}
+
+	return combinedPart{file: pdfFile, pages: pageCount}, nil
+}
+
+func writePdfFile(pdfData []byte, pattern string, serverOptions *ServerOptions) (string, error) {
+	tempFile, err := os.CreateTemp(*serverOptions.DirectoryMap[DirectoryKeyPdf], pattern)
+	if err != nil {
+		return "", errors.New("unable to create output file")
+	}
+	defer tempFile.Close()
+
+	if _, err := tempFile.Write(pdfData); err != nil {
+		return "", fmt.Errorf("unable to write output file: %w", err)
+	}
+
+	return tempFile.Name(), nil
+}
+
+// insertBlankPages inserts a blank page, sized like the page before it, after each of the pages of pdfFile
+func insertBlankPages(pdfFile string, afterPages []int) error {
+	pdfData, err := os.ReadFile(pdfFile)
+	if err != nil {
+		return err
+	}
+
+	conf := model.NewDefaultConfiguration()
+	ctx, err := api.ReadValidateAndOptimize(bytes.NewReader(pdfData), conf)
+	if err != nil {
+		return err
+	}
+
+	pages := types.IntSet{}
+	for _, pageNumber := range afterPages {
+		pages[pageNumber] = true
+	}
+
+	if err := ctx.InsertBlankPages(pages, nil, false); err != nil {
+		return err
+	}
+
+	var output bytes.Buffer
+	if err := api.Write(ctx, &output, conf); err != nil {
+		return err
+	}
+
+	return os.WriteFile(pdfFile, output.Bytes(), 0640)
+}
@@ -3,10 +3,43 @@ package main
 import (
 	"fmt"
 	"net/http/httputil"
+	"regexp"
 
 	"github.com/gin-gonic/gin"
 )
 
+// Request fields that must never be written to the log file
+var sensitiveFields = []string{"userPassword", "ownerPassword"}
+
+var sensitiveFieldPatterns = buildSensitiveFieldPatterns(sensitiveFields)
+
+func buildSensitiveFieldPatterns(fields []string) []*regexp.Regexp {
+	var patterns []*regexp.Regexp
+	for _, field := range fields {
+		name := regexp.QuoteMeta(field)
+		patterns = append(patterns,
+			// json
+			regexp.MustCompile(`("`+name+`"\s*:\s*)"(?:[^"\\]|\\.)*"`),
+			// xml
+			regexp.MustCompile(`(<`+name+`>)[^<]*`),
+			// multipart form
+			regexp.MustCompile(`(name="(?:[^"]*\[)?`+name+`\]?"\r?\n(?:[^\r\n]+\r?\n)*\r?\n)[^\r\n]*`),
+			// url encoded form
+			regexp.MustCompile(`((?:^|[&\s])(?:[^=&\s]*%5B)?`+name+`(?:%5D)?=)[^&\s]*`),
+		)
+	}
+
+	return patterns
+}
+
+func redactSensitiveFields(dump []byte) []byte {
+	for _, pattern := range sensitiveFieldPatterns {
+		dump = pattern.ReplaceAll(dump, []byte("${1}[REDACTED]"))
+	}
+
+	return dump
+}
+
 func LogRequestDataMiddleware(serverOptions *ServerOptions) gin.HandlerFunc {
 
 	return func(ctx *gin.Context) {
@@ -20,7 +53,7 @@ func LogRequestDataMiddleware(serverOptions *ServerOptions) gin.HandlerFunc {
 			// os.WriteFile(serverOptions.LogFile, , os.ModeAppend)
 		}
 
-		serverOptions.LogFile.Write(requestDump)
+		serverOptions.LogFile.Write(redactSensitiveFields(requestDump))
 		serverOptions.LogFile.WriteString("\n")
 
 		ctx.Next()
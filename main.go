@@ -1,8 +1,11 @@
 package main
 
 import (
+	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"log"
 	"net/http"
 	"os"
 	"path/filepath"
@@ -13,13 +16,28 @@ import (
 
 	"github.com/gin-contrib/location"
 	"github.com/gin-gonic/gin"
+	"github.com/pdfcpu/pdfcpu/pkg/api"
 	swaggerfiles "github.com/swaggo/files"
 	ginSwagger "github.com/swaggo/gin-swagger"
 )
 
 type PngResponse struct {
-	Png string `json:"png"`
-	Url string `json:"url"`
+	Png    string `json:"png"`
+	Url    string `json:"url"`
+	Format string `json:"format"`
+	// Images lists every image when a selector matched several elements
+	Images []string `json:"images"`
+	// Viewports lists the images of every requested viewport by name
+	Viewports map[string][]string `json:"viewports,omitempty"`
+}
+
+// renderErrorStatus answers a selector that matches nothing with a 404, every other rendering error with a 400
+func renderErrorStatus(err error) int {
+	if errors.Is(err, ErrNoElementMatched) {
+		return http.StatusNotFound
+	}
+
+	return http.StatusBadRequest
 }
 
 func extractData(c *gin.Context) (*PdfRequest, bool) {
@@ -73,6 +91,7 @@ func extractData(c *gin.Context) (*PdfRequest, bool) {
 // @Param data body PdfRequest true "The input todo struct"
 // @Success 200 {object} PdfResponse
 // @Failure      400
+// @Failure      404
 // @Failure      500
 // @Router /pdf [post]
 func getPdf(c *gin.Context) {
@@ -90,7 +109,7 @@ func getPdf(c *gin.Context) {
 
 	pdfResult, err := buildPdf(pdfRequestParams, options)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "Unable to generate PDF!", "message": err.Error()})
+		c.JSON(renderErrorStatus(err), gin.H{"success": false, "error": "Unable to generate PDF!", "message": err.Error()})
 		return
 	}
 
@@ -108,7 +127,7 @@ func getPdf(c *gin.Context) {
 		outputFiles = append(outputFiles, url+filepath.Base(value))
 	}
 
-	c.IndentedJSON(http.StatusOK, PdfResponse{Url: url + outFileName, Components: outputFiles})
+	c.IndentedJSON(http.StatusOK, PdfResponse{Url: url + outFileName, Components: outputFiles, Signature: pdfResult.Signature, Info: pdfResult.Info})
 }
 
 // @Summary Submit urls/data to be converted to a PDF and then one image per page
@@ -120,6 +139,7 @@ func getPdf(c *gin.Context) {
 // @Param data body PdfRequest true "The input todo struct"
 // @Success 200 {object} PdfPreviewResponse
 // @Failure      400
+// @Failure      404
 // @Failure      500
 // @Router /preview [post]
 func getPdfPreview(c *gin.Context) {
@@ -135,42 +155,436 @@ func getPdfPreview(c *gin.Context) {
 		return
 	}
 
-	pdfResult, err := buildPdf(pdfRequestParams, options)
+	if pdfRequestParams.Encryption != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "Unable to generate PDF!", "message": "encryption is not supported when generating previews"})
+		return
+	}
+
+	previewOptions, err := getPreviewOptions(c, pdfRequestParams.Preview, options)
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "Unable to generate PDF!", "message": err.Error()})
 		return
 	}
 
-	baseName, err := createPreviews(pdfResult.OutputFile.Name(), *options.DirectoryMap[DirectoryKeyPreview])
+	pdfResult, err := buildPdf(pdfRequestParams, options)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "Unable to generate PDF!", "message": err.Error()})
+		c.JSON(renderErrorStatus(err), gin.H{"success": false, "error": "Unable to generate PDF!", "message": err.Error()})
 		return
 	}
 
-	pdfInfo, err := getPdfInfo(pdfResult.OutputFile.Name())
+	response, err := buildPreviewResponse(c, pdfResult.OutputFile.Name(), pdfResult.Info, previewOptions, pdfRequestParams.Text, pdfRequestParams.TextWords, options)
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "Unable to generate PDF!", "message": err.Error()})
 		return
 	}
 
-	pages, err := strconv.ParseInt(pdfInfo["pages"], 10, 8)
+	c.IndentedJSON(http.StatusOK, response)
+}
+
+// @Summary Render page images of a stored or uploaded PDF
+// @Schemes
+// @Description Validate a pdf from /pdfs/, base64 data, a url or an "upload" file and render one image per page like /preview
+// @Accept json
+// @Accept multipart/form-data
+// @Produce json
+// @Param data body PdfSourcePreviewRequest true "The input request"
+// @Success 200 {object} PdfPreviewResponse
+// @Failure      400
+// @Failure      500
+// @Router /pdf/preview [post]
+func getPdfSourcePreview(c *gin.Context) {
+	options, ok := c.MustGet("serverOptions").(*ServerOptions)
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Unable to retrieve data to preview PDF!", "message": "Error retrieving ServerOptions"})
+		return
+	}
+
+	var previewRequestParams PdfSourcePreviewRequest
+
+	// Handle JSON/Form-Data
+	err := c.ShouldBind(&previewRequestParams)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "Unable to extract request data", "details": err.Error()})
+		return
+	}
+
+	previewOptions, err := getPreviewOptions(c, previewRequestParams.Preview, options)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "Unable to preview PDF!", "message": err.Error()})
+		return
+	}
+
+	pdfData, err := readPdfSource(c, &previewRequestParams.PdfSource, options)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "Unable to read PDF!", "message": err.Error()})
+		return
+	}
+
+	// Only lazy previews keep the pdf in the pdfs directory, they render it when their images are requested
+	pdfFile, err := writePdfFile(pdfData, "*-source.pdf", options)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "Unable to preview PDF!", "message": err.Error()})
+		return
+	}
+
+	keepPdfFile := false
+	defer func() {
+		if !keepPdfFile {
+			os.Remove(pdfFile)
+		}
+	}()
+
+	pdfInfo, err := getPdfInfo(pdfFile, options)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "Unable to preview PDF!", "message": err.Error()})
+		return
+	}
+
+	response, err := buildPreviewResponse(c, pdfFile, pdfInfo, previewOptions, previewRequestParams.Text, previewRequestParams.TextWords, options)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "Unable to preview PDF!", "message": err.Error()})
+		return
+	}
+
+	keepPdfFile = previewOptions.Lazy
+	c.IndentedJSON(http.StatusOK, response)
+}
+
+// @Summary Apply page operations to a stored or uploaded PDF
+// @Schemes
+// @Description Select, rotate, reorder, delete or split the pages of a pdf from /pdfs/, base64 data or an "upload" file
+// @Accept json
+// @Accept xml
+// @Accept multipart/form-data
+// @Produce json
+// @Param data body PdfTransformRequest true "The input request"
+// @Success 200 {object} PdfResponse
+// @Failure      400
+// @Failure      500
+// @Router /pdf/transform [post]
+func getPdfTransform(c *gin.Context) {
+	options, ok := c.MustGet("serverOptions").(*ServerOptions)
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Unable to retrieve data to transform PDF!", "message": "Error retrieving ServerOptions"})
+		return
+	}
+
+	var transformRequestParams PdfTransformRequest
+
+	// Handle JSON/XML/Form-Data
+	err := c.ShouldBind(&transformRequestParams)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "Unable to extract request data", "details": err.Error()})
+		return
+	}
+
+	// Form submissions provide the operations as a json string
+	if operations := c.PostForm("operations"); operations != "" && transformRequestParams.Operations == nil {
+		err := json.Unmarshal([]byte(operations), &transformRequestParams.Operations)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "Unable to extract request data", "details": err.Error()})
+			return
+		}
+	}
+
+	if len(transformRequestParams.Operations) <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "No Operations", "details": "transformRequestParams.Operations is empty"})
+		return
+	}
+
+	pdfData, err := readPdfSource(c, &transformRequestParams.PdfSource, options)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "Unable to read PDF!", "message": err.Error()})
+		return
+	}
+
+	outputFiles, err := transformPdf(pdfData, transformRequestParams.Operations, options)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "Unable to transform PDF!", "message": err.Error()})
+		return
+	}
+
+	if transformRequestParams.Download {
+		if len(outputFiles) != 1 {
+			c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "Unable to download PDF!", "message": "download requires the operations to produce a single pdf"})
+			return
+		}
+
+		c.FileAttachment(outputFiles[0], "output.pdf")
+		return
+	}
+
+	var urls []string
+	serverUrl := location.Get(c)
+	url := serverUrl.Scheme + "://" + serverUrl.Host + "/pdfs/"
+
+	for _, value := range outputFiles {
+		urls = append(urls, url+filepath.Base(value))
+	}
+
+	response := PdfResponse{Components: urls}
+	if len(urls) == 1 {
+		response.Url = urls[0]
+	}
+
+	c.IndentedJSON(http.StatusOK, response)
+}
+
+// @Summary Fill the form fields of a stored or uploaded PDF
+// @Schemes
+// @Description Set the fields of a pdf form from /pdfs/, base64 data or an "upload" file and optionally flatten it
+// @Accept json
+// @Accept multipart/form-data
+// @Produce json
+// @Param data body PdfFormRequest true "The input request"
+// @Success 200 {object} PdfResponse
+// @Failure      400
+// @Failure      500
+// @Router /pdf/form [post]
+func getPdfForm(c *gin.Context) {
+	options, ok := c.MustGet("serverOptions").(*ServerOptions)
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Unable to retrieve data to fill PDF form!", "message": "Error retrieving ServerOptions"})
+		return
+	}
+
+	var formRequestParams PdfFormRequest
+
+	// Handle JSON/Form-Data
+	err := c.ShouldBind(&formRequestParams)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "Unable to extract request data", "details": err.Error()})
+		return
+	}
+
+	// Form submissions provide the fields as a json string
+	if fields := c.PostForm("fields"); fields != "" && formRequestParams.Fields == nil {
+		err := json.Unmarshal([]byte(fields), &formRequestParams.Fields)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "Unable to extract request data", "details": err.Error()})
+			return
+		}
+	}
+
+	if len(formRequestParams.Fields) <= 0 && !formRequestParams.Flatten {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "No Fields", "details": "formRequestParams.Fields is empty"})
+		return
+	}
+
+	pdfData, err := readPdfSource(c, &formRequestParams.PdfSource, options)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "Unable to generate PDF!", "message": "Unable to compute number of pages"})
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "Unable to read PDF!", "message": err.Error()})
 		return
 	}
+
+	filledData, err := fillPdfForm(pdfData, formRequestParams.Fields, formRequestParams.Flatten)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "Unable to fill PDF form!", "message": err.Error()})
+		return
+	}
+
+	outputFile, err := writePdfFile(filledData, "*-form.pdf", options)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": "Unable to fill PDF form!", "message": err.Error()})
+		return
+	}
+
+	if formRequestParams.Download {
+		c.FileAttachment(outputFile, "output.pdf")
+		return
+	}
+
 	serverUrl := location.Get(c)
-	url := serverUrl.Scheme + "://" + serverUrl.Host + "/preview/"
+	url := serverUrl.Scheme + "://" + serverUrl.Host + "/pdfs/" + filepath.Base(outputFile)
+
+	c.IndentedJSON(http.StatusOK, PdfResponse{Url: url, Components: []string{url}})
+}
 
-	// pdftocairo prepends 0 to the page name, so we need to return the correct name prepended as well
-	numberOfDigits := strconv.Itoa(int(pages))
-	format := fmt.Sprintf("%s%s-%s%d%s.jpg", "%s", "%s", "%0", len(numberOfDigits), "d")
+// @Summary List the form fields of a stored or uploaded PDF
+// @Schemes
+// @Description List the name, type, value and allowed values of every field of a pdf form from /pdfs/, base64 data or an "upload" file
+// @Accept json
+// @Accept multipart/form-data
+// @Produce json
+// @Param data body PdfFormFieldsRequest true "The input request"
+// @Success 200 {object} PdfFormFieldsResponse
+// @Failure      400
+// @Failure      500
+// @Router /pdf/form/fields [post]
+func getPdfFormFieldList(c *gin.Context) {
+	options, ok := c.MustGet("serverOptions").(*ServerOptions)
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Unable to retrieve data to read PDF form!", "message": "Error retrieving ServerOptions"})
+		return
+	}
 
-	var images []string
-	for i := range pages {
-		images = append(images, fmt.Sprintf(format, url, baseName, i+1))
+	var fieldsRequestParams PdfFormFieldsRequest
+
+	// Handle JSON/Form-Data
+	err := c.ShouldBind(&fieldsRequestParams)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "Unable to extract request data", "details": err.Error()})
+		return
+	}
+
+	pdfData, err := readPdfSource(c, &fieldsRequestParams.PdfSource, options)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "Unable to read PDF!", "message": err.Error()})
+		return
 	}
 
-	c.IndentedJSON(http.StatusOK, PdfPreviewResponse{Pages: int8(pages), Images: images, pdfInfo: pdfInfo})
+	fields, err := getPdfFormFields(pdfData)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "Unable to read PDF form!", "message": err.Error()})
+		return
+	}
+
+	c.IndentedJSON(http.StatusOK, PdfFormFieldsResponse{Fields: fields})
+}
+
+// @Summary Get the image of a single page of a generated PDF
+// @Schemes
+// @Description Render a page of a pdf in /pdfs/ the first time it is requested and serve the cached image afterwards. The page is named <page>.jpg, <page>.png or <page>.webp.
+// @Produce jpeg
+// @Produce png
+// @Param id path string true "The name of the pdf without its extension"
+// @Param page path string true "The page number and image format like 3.jpg"
+// @Param width query int false "Image width in pixels"
+// @Param dpi query int false "Image resolution"
+// @Param quality query int false "jpeg and webp quality from 1 to 100"
+// @Success 200
+// @Failure      400
+// @Failure      404
+// @Router /documents/{id}/pages/{page} [get]
+func getDocumentPage(c *gin.Context) {
+	options, ok := c.MustGet("serverOptions").(*ServerOptions)
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Unable to retrieve data to render page!", "message": "Error retrieving ServerOptions"})
+		return
+	}
+
+	var pageRequestParams PageImageRequest
+	if err := c.ShouldBindQuery(&pageRequestParams); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "Unable to extract request data", "details": err.Error()})
+		return
+	}
+
+	if err := validatePageImageRequest(pageRequestParams); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "Unable to render page!", "message": err.Error()})
+		return
+	}
+
+	pageNumber, format, err := parsePageImage(c.Param("page"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "Unable to render page!", "message": err.Error()})
+		return
+	}
+
+	pdfFile, err := documentPdfFile(c.Param("id"), options)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"success": false, "error": "Unable to render page!", "message": err.Error()})
+		return
+	}
+
+	image, err := getPageImage(pdfFile, pageNumber, format, pageRequestParams, options)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "Unable to render page!", "message": err.Error()})
+		return
+	}
+
+	c.File(image)
+}
+
+// @Summary Describe a stored or uploaded PDF
+// @Schemes
+// @Description Return the page sizes, version, encryption, metadata, fonts and tagged or linearized status of a pdf from /pdfs/, base64 data or an "upload" file
+// @Accept json
+// @Accept multipart/form-data
+// @Produce json
+// @Param data body PdfInfoRequest true "The input request"
+// @Success 200 {object} PdfInfo
+// @Failure      400
+// @Failure      500
+// @Router /info [post]
+func getPdfInfoDetails(c *gin.Context) {
+	options, ok := c.MustGet("serverOptions").(*ServerOptions)
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Unable to retrieve data to read PDF!", "message": "Error retrieving ServerOptions"})
+		return
+	}
+
+	var infoRequestParams PdfInfoRequest
+
+	// Handle JSON/Form-Data
+	err := c.ShouldBind(&infoRequestParams)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "Unable to extract request data", "details": err.Error()})
+		return
+	}
+
+	pdfData, err := readPdfSource(c, &infoRequestParams.PdfSource, options)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "Unable to read PDF!", "message": err.Error()})
+		return
+	}
+
+	pdfInfo, err := getPdfDataInfo(pdfData, options)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "Unable to read PDF!", "message": err.Error()})
+		return
+	}
+
+	c.IndentedJSON(http.StatusOK, pdfInfo)
+}
+
+// @Summary Extract the text of a stored or uploaded PDF
+// @Schemes
+// @Description Extract the text of every page of a pdf from /pdfs/, base64 data or an "upload" file, optionally with word boxes in preview image pixels
+// @Accept json
+// @Accept multipart/form-data
+// @Produce json
+// @Param data body PdfTextRequest true "The input request"
+// @Success 200 {object} PdfTextResponse
+// @Failure      400
+// @Failure      500
+// @Router /text [post]
+func getPdfText(c *gin.Context) {
+	options, ok := c.MustGet("serverOptions").(*ServerOptions)
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Unable to retrieve data to extract PDF text!", "message": "Error retrieving ServerOptions"})
+		return
+	}
+
+	var textRequestParams PdfTextRequest
+
+	// Handle JSON/Form-Data
+	err := c.ShouldBind(&textRequestParams)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "Unable to extract request data", "details": err.Error()})
+		return
+	}
+
+	pdfData, err := readPdfSource(c, &textRequestParams.PdfSource, options)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "Unable to read PDF!", "message": err.Error()})
+		return
+	}
+
+	// pdftotext reads from a file
+	pdfFile, err := writePdfFile(pdfData, "*-text.pdf", options)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "Unable to read PDF!", "message": err.Error()})
+		return
+	}
+	defer os.Remove(pdfFile)
+
+	pages, err := extractPdfText(pdfFile, textRequestParams.Words, PdfPreviewSize{}, options)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "Unable to extract PDF text!", "message": err.Error()})
+		return
+	}
+
+	c.IndentedJSON(http.StatusOK, PdfTextResponse{Text: joinPageText(pages), Pages: pages})
 }
 
 // @Summary Submit a single url or data to be converted to a png
@@ -182,6 +596,7 @@ func getPdfPreview(c *gin.Context) {
 // @Param data body PngRequest true "The input request"
 // @Success 200 {object} PngResponse
 // @Failure      400
+// @Failure      404
 // @Failure      500
 // @Router /png [post]
 func getPng(c *gin.Context) {
@@ -205,22 +620,225 @@ func getPng(c *gin.Context) {
 		return
 	}
 
-	outputFile, err := buildPng(&pngRequestParams, options)
+	if viewports := c.PostForm("viewports"); viewports != "" && pngRequestParams.Viewports == nil {
+		if err := json.Unmarshal([]byte(viewports), &pngRequestParams.Viewports); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "Unable to extract request data", "details": "invalid viewports: " + err.Error()})
+			return
+		}
+	}
+
+	captures, err := buildPng(&pngRequestParams, options)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "Unable to generate screenshot!", "message": err.Error()})
+		c.JSON(renderErrorStatus(err), gin.H{"success": false, "error": "Unable to generate screenshot!", "message": err.Error()})
 		return
 	}
 
+	// Downloads return the first image when a selector or the viewports produced several
 	if pngRequestParams.Download {
-		c.FileAttachment(outputFile.Name(), "output.pdf")
+		c.FileAttachment(captures[0].File.Name(), "output."+previewExtensions[pngRequestParams.Format])
+		return
+	}
+
+	serverUrl := location.Get(c)
+	url := serverUrl.Scheme + "://" + serverUrl.Host + "/png/"
+
+	c.IndentedJSON(http.StatusOK, newPngResponse(captures, pngRequestParams.Format, url))
+}
+
+// @Summary Submit many urls or data to be converted to images
+// @Schemes
+// @Description Capture many sources concurrently, each item is the html or url to capture or a screenshot request whose options replace the defaults
+// @Accept json
+// @Produce json
+// @Param data body PngBatchRequest true "The input request"
+// @Success 200 {object} PngBatchResponse
+// @Failure      400
+// @Failure      500
+// @Router /png/batch [post]
+func getPngBatch(c *gin.Context) {
+	options, ok := c.MustGet("serverOptions").(*ServerOptions)
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Unable to retrieve data to generate screenshots!", "message": "Error retrieving ServerOptions"})
+		return
+	}
+
+	var batchRequestParams PngBatchRequest
+	if err := c.ShouldBindJSON(&batchRequestParams); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "Unable to extract request data", "details": err.Error()})
+		return
+	}
+
+	results, err := buildPngBatch(&batchRequestParams, options)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "Unable to generate screenshots!", "message": err.Error()})
+		return
+	}
+
+	if batchRequestParams.Download {
+		// The zip is streamed, once it has started an error can only cut it short
+		c.Header("Content-Disposition", `attachment; filename="output.zip"`)
+		c.Header("Content-Type", "application/zip")
+		c.Status(http.StatusOK)
+
+		if err := writePngBatchZip(results, c.Writer); err != nil {
+			log.Printf("Unable to write screenshot batch zip: %s", err.Error())
+		}
 		return
 	}
 
-	outFileName := filepath.Base(outputFile.Name())
 	serverUrl := location.Get(c)
 	url := serverUrl.Scheme + "://" + serverUrl.Host + "/png/"
 
-	c.IndentedJSON(http.StatusOK, PngResponse{Png: outFileName, Url: url + outFileName})
+	c.IndentedJSON(http.StatusOK, newPngBatchResponse(results, url))
+}
+
+// @Summary Record an animation of a url or data loading
+// @Schemes
+// @Description Record a page while it loads, and optionally scrolls to the bottom, as an animated gif or webp
+// @Accept json
+// @Accept xml
+// @Produce json
+// @Param data body AnimationRequest true "The input request"
+// @Success 200 {object} AnimationResponse
+// @Failure      400
+// @Failure      500
+// @Router /png/animation [post]
+func getAnimation(c *gin.Context) {
+	options, ok := c.MustGet("serverOptions").(*ServerOptions)
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Unable to retrieve data to record animation!", "message": "Error retrieving ServerOptions"})
+		return
+	}
+
+	var animationRequestParams AnimationRequest
+
+	// Handle JSON/XML/Form-Data
+	if err := c.ShouldBind(&animationRequestParams); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "Unable to extract request data", "details": err.Error()})
+		return
+	}
+
+	if len(animationRequestParams.Data) <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "No Data", "details": "animationRequestParams.Data is empty"})
+		return
+	}
+
+	animationResult, err := buildAnimation(&animationRequestParams, options)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "Unable to record animation!", "message": err.Error()})
+		return
+	}
+
+	if animationRequestParams.Download {
+		c.FileAttachment(animationResult.OutputFile.Name(), "output."+animationRequestParams.Format)
+		return
+	}
+
+	outFileName := filepath.Base(animationResult.OutputFile.Name())
+	serverUrl := location.Get(c)
+	url := serverUrl.Scheme + "://" + serverUrl.Host + "/png/"
+
+	c.IndentedJSON(http.StatusOK, AnimationResponse{
+		Animation: outFileName,
+		Url:       url + outFileName,
+		Format:    animationRequestParams.Format,
+		Frames:    animationResult.Frames,
+		Duration:  animationResult.Duration,
+	})
+}
+
+// @Summary Submit a single url or data to be archived
+// @Schemes
+// @Description Archive a page as Chrome rendered it, as mhtml with every resource or as the html of the document after its scripts ran
+// @Accept json
+// @Accept xml
+// @Produce json
+// @Param data body ArchiveRequest true "The input request"
+// @Success 200 {object} ArchiveResponse
+// @Failure      400
+// @Failure      500
+// @Router /archive [post]
+func getArchive(c *gin.Context) {
+	options, ok := c.MustGet("serverOptions").(*ServerOptions)
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Unable to retrieve data to generate archive!", "message": "Error retrieving ServerOptions"})
+		return
+	}
+
+	var archiveRequestParams ArchiveRequest
+
+	// Handle JSON/XML/Form-Data
+	if err := c.ShouldBind(&archiveRequestParams); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "Unable to extract request data", "details": err.Error()})
+		return
+	}
+
+	if len(archiveRequestParams.Data) <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "No Data", "details": "archiveRequestParams.Data is empty"})
+		return
+	}
+
+	archiveResult, err := buildArchive(&archiveRequestParams, options)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "Unable to generate archive!", "message": err.Error()})
+		return
+	}
+
+	if archiveRequestParams.Download {
+		c.FileAttachment(archiveResult.OutputFile.Name(), "output."+archiveRequestParams.Format)
+		return
+	}
+
+	outFileName := filepath.Base(archiveResult.OutputFile.Name())
+	serverUrl := location.Get(c)
+	url := serverUrl.Scheme + "://" + serverUrl.Host + "/archives/"
+
+	c.IndentedJSON(http.StatusOK, ArchiveResponse{Archive: outFileName, Url: url + outFileName, Format: archiveRequestParams.Format, Title: archiveResult.Title})
+}
+
+// @Summary Compare images or pdfs page by page
+// @Schemes
+// @Description Compare an expected image or pdf with an actual one, or with the rendering of a screenshot or pdf request, and highlight the changed pixels of every page
+// @Accept json
+// @Accept mpfd
+// @Produce json
+// @Param data body DiffRequest true "The input request"
+// @Success 200 {object} DiffResponse
+// @Failure      400
+// @Failure      500
+// @Router /diff [post]
+func getDiff(c *gin.Context) {
+	options, ok := c.MustGet("serverOptions").(*ServerOptions)
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Unable to retrieve data to compare!", "message": "Error retrieving ServerOptions"})
+		return
+	}
+
+	var diffRequestParams DiffRequest
+
+	// Handle JSON/XML/Form-Data
+	if err := c.ShouldBind(&diffRequestParams); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "Unable to extract request data", "details": err.Error()})
+		return
+	}
+
+	if err := getDiffRequestForm(c, &diffRequestParams); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "Unable to extract request data", "details": err.Error()})
+		return
+	}
+
+	if err := validateDiffRequest(&diffRequestParams); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "Unable to compare!", "message": err.Error()})
+		return
+	}
+
+	response, err := buildDiff(c, &diffRequestParams, options)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "Unable to compare!", "message": err.Error()})
+		return
+	}
+
+	c.IndentedJSON(http.StatusOK, response)
 }
 
 func getStatus(c *gin.Context) {
@@ -237,6 +855,10 @@ func main() {
 	var serverOptions *ServerOptions
 	serverOptions = New(serverOptions)
 	createDirectories(serverOptions)
+	loadSigningIdentities(serverOptions)
+
+	// pdfcpu would otherwise create and read a config.yml in the user's config directory
+	api.DisableConfigDir()
 
 	gin.DisableConsoleColor()
 
@@ -258,12 +880,24 @@ func main() {
 	}
 
 	router.POST("/pdf", getPdf)
+	router.POST("/pdf/transform", getPdfTransform)
+	router.POST("/pdf/form", getPdfForm)
+	router.POST("/pdf/form/fields", getPdfFormFieldList)
+	router.POST("/pdf/preview", getPdfSourcePreview)
 	router.POST("/preview", getPdfPreview)
+	router.POST("/text", getPdfText)
+	router.POST("/info", getPdfInfoDetails)
+	router.GET("/documents/:id/pages/:page", getDocumentPage)
 	router.POST("/png", getPng)
+	router.POST("/png/batch", getPngBatch)
+	router.POST("/png/animation", getAnimation)
+	router.POST("/diff", getDiff)
+	router.POST("/archive", getArchive)
 	router.GET("/status", getStatus)
 	router.Static("/pdfs", *serverOptions.DirectoryMap[DirectoryKeyPdf])
 	router.Static("/png", *serverOptions.DirectoryMap[DirectoryKeyPng])
 	router.Static("/preview", *serverOptions.DirectoryMap[DirectoryKeyPreview])
+	router.Group("/archives", ArchiveHeadersMiddleware()).Static("/", *serverOptions.DirectoryMap[DirectoryKeyArchive])
 
 	docs.SwaggerInfo.BasePath = "/"
 	router.GET("/swagger/*any", ginSwagger.WrapHandler(swaggerfiles.Handler))
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"html/template"
+	"regexp"
+	"strings"
+
+	"github.com/yuin/goldmark"
+	highlighting "github.com/yuin/goldmark-highlighting/v2"
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/extension"
+	"github.com/yuin/goldmark/parser"
+	"github.com/yuin/goldmark/renderer/html"
+	"github.com/yuin/goldmark/text"
+)
+
+// closingStylePattern matches what would end the style element early, the stylesheet may come from the request
+var closingStylePattern = regexp.MustCompile(`(?i)</(style)`)
+
+var markdownDataUriPattern = regexp.MustCompile(`(?is)^data:text/(?:x-)?markdown[;,]`)
+
+// markdownRenderer renders CommonMark with the GitHub extensions. Headings get ids so they can be linked to and
+// fenced code blocks are highlighted with inline styles. Raw html is kept, data entries may be html anyway.
+var markdownRenderer = goldmark.New(
+	goldmark.WithExtensions(
+		extension.GFM,
+		highlighting.NewHighlighting(highlighting.WithStyle("github")),
+	),
+	goldmark.WithParserOptions(parser.WithAutoHeadingID()),
+	goldmark.WithRendererOptions(html.WithUnsafe()),
+)
+
+var markdownDocumentTemplate = template.Must(template.New("markdown").Parse(`<!DOCTYPE html>
+<html>
+<head>
+    <meta charset="utf-8">
+    <title>{{.Title}}</title>
+    <style>{{.Stylesheet}}</style>
+</head>
+<body>
+{{.Body}}
+</body>
+</html>
+`))
+
+type markdownDocument struct {
+	Title      string
+	Stylesheet template.CSS
+	Body       template.HTML
+}
+
+// loadMarkdownSource returns the markdown of data:text/markdown entries, isMarkdown is false for other entries
+func loadMarkdownSource(requestDataOrUrl string) (markdown []byte, isMarkdown bool, err error) {
+	trimmed := strings.TrimSpace(requestDataOrUrl)
+	if !markdownDataUriPattern.MatchString(trimmed) {
+		return nil, false, nil
+	}
+
+	_, markdown, err = decodeDataUri(trimmed)
+	if err != nil {
+		return nil, true, fmt.Errorf("unable to decode markdown: %w", err)
+	}
+
+	return markdown, true, nil
+}
+
+// renderMarkdown converts markdown to an html document styled with stylesheet, titled after its first heading
+func renderMarkdown(markdown []byte, stylesheet string) (string, error) {
+	document := markdownRenderer.Parser().Parse(text.NewReader(markdown))
+
+	var body bytes.Buffer
+	if err := markdownRenderer.Renderer().Render(&body, markdown, document); err != nil {
+		return "", fmt.Errorf("unable to render markdown: %w", err)
+	}
+
+	var title string
+	ast.Walk(document, func(node ast.Node, entering bool) (ast.WalkStatus, error) {
+		if heading, ok := node.(*ast.Heading); ok && entering {
+			title = markdownNodeText(heading, markdown)
+			return ast.WalkStop, nil
+		}
+
+		return ast.WalkContinue, nil
+	})
+
+	var html bytes.Buffer
+	err := markdownDocumentTemplate.Execute(&html, markdownDocument{
+		Title:      title,
+		Stylesheet: template.CSS(closingStylePattern.ReplaceAllString(stylesheet, `<\/$1`)),
+		Body:       template.HTML(body.String()),
+	})
+	if err != nil {
+		return "", fmt.Errorf("unable to render markdown: %w", err)
+	}
+
+	return html.String(), nil
+}
+
+// markdownNodeText returns the plain text of an inline node and its children
+func markdownNodeText(node ast.Node, source []byte) string {
+	var nodeText strings.Builder
+	ast.Walk(node, func(child ast.Node, entering bool) (ast.WalkStatus, error) {
+		if !entering {
+			return ast.WalkContinue, nil
+		}
+
+		switch inline := child.(type) {
+		case *ast.Text:
+			nodeText.Write(inline.Segment.Value(source))
+			if inline.SoftLineBreak() || inline.HardLineBreak() {
+				nodeText.WriteString(" ")
+			}
+		case *ast.String:
+			nodeText.Write(inline.Value)
+		case *ast.CodeSpan:
+			for codeChild := inline.FirstChild(); codeChild != nil; codeChild = codeChild.NextSibling() {
+				if codeText, ok := codeChild.(*ast.Text); ok {
+					nodeText.Write(codeText.Segment.Value(source))
+				}
+			}
+			return ast.WalkSkipChildren, nil
+		}
+
+		return ast.WalkContinue, nil
+	})
+
+	return strings.TrimSpace(nodeText.String())
+}
@@ -0,0 +1,249 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+var pageImagePattern = regexp.MustCompile(`^(\d+)\.(jpg|png|webp)$`)
+
+const (
+	maxPageImageWidth = 4096
+	maxPageImageDpi   = 600
+)
+
+var previewExtensions = map[string]string{
+	PreviewFormatJpeg: "jpg",
+	PreviewFormatPng:  "png",
+	PreviewFormatWebp: "webp",
+}
+
+// pageImageLocks holds a lock per cached image while requests use it so concurrent requests render a page only once
+var pageImageLocks = struct {
+	sync.Mutex
+	locks map[string]*pageImageLock
+}{locks: make(map[string]*pageImageLock)}
+
+type pageImageLock struct {
+	sync.Mutex
+	users int
+}
+
+type PageImageRequest struct {
+	Width   int `form:"width"`
+	Dpi     int `form:"dpi"`
+	Quality int `form:"quality"`
+}
+
+// parsePageImage splits a page image name like 3.jpg into the page number and preview format
+func parsePageImage(name string) (int, string, error) {
+	matches := pageImagePattern.FindStringSubmatch(name)
+	if matches == nil {
+		return 0, "", fmt.Errorf("invalid page image %q, expected <page>.jpg, <page>.png or <page>.webp", name)
+	}
+
+	pageNumber, err := strconv.Atoi(matches[1])
+	if err != nil {
+		return 0, "", fmt.Errorf("invalid page number %q", matches[1])
+	}
+
+	for format, extension := range previewExtensions {
+		if extension == matches[2] {
+			return pageNumber, format, nil
+		}
+	}
+
+	return 0, "", fmt.Errorf("invalid page image %q", name)
+}
+
+func validatePageImageRequest(request PageImageRequest) error {
+	if request.Width < 0 || request.Width > maxPageImageWidth {
+		return fmt.Errorf("width must be between 1 and %d", maxPageImageWidth)
+	}
+
+	if request.Dpi < 0 || request.Dpi > maxPageImageDpi {
+		return fmt.Errorf("dpi must be between 1 and %d", maxPageImageDpi)
+	}
+
+	if request.Width > 0 && request.Dpi > 0 {
+		return errors.New("set either width or dpi")
+	}
+
+	if request.Quality < 0 || request.Quality > 100 {
+		return errors.New("quality must be between 1 and 100")
+	}
+
+	return nil
+}
+
+// documentPdfFile returns the pdf of a document id, the name of a pdf in the pdfs directory without its extension
+func documentPdfFile(id string, options *ServerOptions) (string, error) {
+	pdfFile := filepath.Join(*options.DirectoryMap[DirectoryKeyPdf], filepath.Base(id)+".pdf")
+	if !pathExists(pdfFile) {
+		return "", fmt.Errorf("document %s does not exist", filepath.Base(id))
+	}
+
+	return pdfFile, nil
+}
+
+// getPageImage returns the image of a page, rendering it the first time it is requested
+func getPageImage(pdfFile string, pageNumber int, format string, request PageImageRequest, options *ServerOptions) (string, error) {
+	if options.Rasterizer == nil {
+		return "", errors.New("unable to render page: no rasterizer is available, install poppler-utils")
+	}
+
+	if format == PreviewFormatWebp && options.WebpEncoder == nil {
+		return "", errors.New("webp previews are unavailable, install libwebp-tools")
+	}
+
+	// Every combination of options is cached under its own name
+	name := []string{fileNameWithoutExtension(filepath.Base(pdfFile)), "page", strconv.Itoa(pageNumber)}
+	if request.Width > 0 {
+		name = append(name, "w"+strconv.Itoa(request.Width))
+	}
+	if request.Dpi > 0 {
+		name = append(name, "d"+strconv.Itoa(request.Dpi))
+	}
+	if request.Quality > 0 {
+		name = append(name, "q"+strconv.Itoa(request.Quality))
+	}
+	cachePrefix := filepath.Join(*options.DirectoryMap[DirectoryKeyPreview], strings.Join(name, "-"))
+	cacheFile := cachePrefix + "." + previewExtensions[format]
+
+	unlock := lockPageImage(cacheFile)
+	defer unlock()
+
+	if pathExists(cacheFile) {
+		return cacheFile, nil
+	}
+
+	pageCount, err := getPdfPageCount(pdfFile, options)
+	if err != nil {
+		return "", err
+	}
+
+	if pageNumber < 1 || pageNumber > pageCount {
+		return "", fmt.Errorf("page %d does not exist, the document has %d pages", pageNumber, pageCount)
+	}
+
+	rasterFormat := format
+	if rasterFormat == PreviewFormatWebp {
+		rasterFormat = PreviewFormatPng
+	}
+
+	images, err := options.Rasterizer.Rasterize(pdfFile, cachePrefix, RasterOptions{
+		Format:    rasterFormat,
+		Quality:   request.Quality,
+		Dpi:       request.Dpi,
+		Width:     request.Width,
+		FirstPage: pageNumber,
+		LastPage:  pageNumber,
+	})
+	if err != nil {
+		return "", fmt.Errorf("unable to render page: %w", err)
+	}
+
+	image, found := images[pageNumber]
+	if !found {
+		return "", fmt.Errorf("unable to render page: page %d was not rendered", pageNumber)
+	}
+
+	if format == PreviewFormatWebp {
+		image, err = convertToWebp(image, request.Quality, options)
+		if err != nil {
+			return "", fmt.Errorf("unable to render page: %w", err)
+		}
+	}
+
+	if err := os.Rename(image, cacheFile); err != nil {
+		return "", fmt.Errorf("unable to render page: %w", err)
+	}
+
+	return cacheFile, nil
+}
+
+// lockPageImage locks cacheFile and returns the function that unlocks it, the lock is dropped once nothing uses it
+func lockPageImage(cacheFile string) func() {
+	pageImageLocks.Lock()
+	lock, found := pageImageLocks.locks[cacheFile]
+	if !found {
+		lock = &pageImageLock{}
+		pageImageLocks.locks[cacheFile] = lock
+	}
+	lock.users++
+	pageImageLocks.Unlock()
+
+	lock.Lock()
+
+	return func() {
+		lock.Unlock()
+
+		pageImageLocks.Lock()
+		lock.users--
+		if lock.users == 0 {
+			delete(pageImageLocks.locks, cacheFile)
+		}
+		pageImageLocks.Unlock()
+	}
+}
+
+// removePageImages removes the cached page images of the document pdfFile from previewDirectory
+func removePageImages(pdfFile string, previewDirectory string) {
+	entries, err := os.ReadDir(previewDirectory)
+	if err != nil {
+		return
+	}
+
+	// The names getPageImage caches under, not those of a document whose id continues with -page-
+	id := fileNameWithoutExtension(filepath.Base(pdfFile))
+	pattern := regexp.MustCompile(`^` + regexp.QuoteMeta(id) + `-page-\d+(-w\d+)?(-d\d+)?(-q\d+)?\.(jpg|png|webp)$`)
+	for _, entry := range entries {
+		if pattern.MatchString(entry.Name()) {
+			os.Remove(filepath.Join(previewDirectory, entry.Name()))
+		}
+	}
+}
+
+// lazyPreviews returns the /documents paths of the selected pages at every requested size without rendering them
+func lazyPreviews(pdfFile string, pageCount int, previewOptions *PdfPreviewOptions) ([]PdfPreviewPage, error) {
+	pageNumbers, err := selectPreviewPages(previewOptions.Pages, pageCount)
+	if err != nil {
+		return nil, err
+	}
+
+	id := url.PathEscape(fileNameWithoutExtension(filepath.Base(pdfFile)))
+
+	pages := make([]PdfPreviewPage, len(pageNumbers))
+	for index, pageNumber := range pageNumbers {
+		pages[index] = PdfPreviewPage{Page: pageNumber, Images: make(map[string]string)}
+
+		for _, size := range previewOptions.Sizes {
+			query := url.Values{}
+			if size.Width > 0 {
+				query.Set("width", strconv.Itoa(size.Width))
+			}
+			if size.Dpi > 0 {
+				query.Set("dpi", strconv.Itoa(size.Dpi))
+			}
+			if previewOptions.Quality > 0 && previewOptions.Format != PreviewFormatPng {
+				query.Set("quality", strconv.Itoa(previewOptions.Quality))
+			}
+
+			path := fmt.Sprintf("/documents/%s/pages/%d.%s", id, pageNumber, previewExtensions[previewOptions.Format])
+			if len(query) > 0 {
+				path += "?" + query.Encode()
+			}
+
+			pages[index].Images[size.Name] = path
+		}
+	}
+
+	return pages, nil
+}
@@ -21,37 +21,76 @@ import (
 )
 
 type PdfRequest struct {
-	Data         []string  `json:"data" form:"data"`
-	Download     bool      `json:"download" form:"download"`
-	Header       *string   `json:"header" form:"header"`
-	Footer       *string   `json:"footer" form:"footer"`
-	MarginTop    *float32  `json:"marginTop" form:"marginTop"`
-	MarginBottom *float32  `json:"marginBottom" form:"marginBottom"`
-	MarginLeft   *float32  `json:"marginLeft"  form:"marginLeft"`
-	MarginRight  *float32  `json:"marginRight" form:"marginRight"`
-	PaperSize    []float64 `json:"paperSize" form:"paperSize"`
+	Data            []string            `json:"data" form:"data"`
+	Download        bool                `json:"download" form:"download"`
+	Header          *string             `json:"header" form:"header"`
+	Footer          *string             `json:"footer" form:"footer"`
+	MarginTop       *float32            `json:"marginTop" form:"marginTop"`
+	MarginBottom    *float32            `json:"marginBottom" form:"marginBottom"`
+	MarginLeft      *float32            `json:"marginLeft"  form:"marginLeft"`
+	MarginRight     *float32            `json:"marginRight" form:"marginRight"`
+	PaperSize       []float64           `json:"paperSize" form:"paperSize"`
+	Encryption      *PdfEncryption      `json:"encryption" form:"encryption"`
+	Signature       *PdfSignature       `json:"signature" form:"signature"`
+	Duplex          bool                `json:"duplex" form:"duplex"`
+	Cover           *string             `json:"cover" form:"cover"`
+	Separator       *string             `json:"separator" form:"separator"`
+	Imposition      *PdfImposition      `json:"imposition" form:"imposition"`
+	TableOfContents *PdfTableOfContents `json:"tableOfContents" form:"tableOfContents"`
+	FormFields      bool                `json:"formFields" form:"formFields"`
+	Continuous      bool                `json:"continuous" form:"continuous"`
+	// Selectors holds a css selector for the data entry at the same index, only the first visible element it matches
+	// is printed. Entries without one, or with an empty one, are printed in full.
+	Selectors []string `json:"selectors" form:"selectors"`
+	// MarkdownStylesheet replaces the stylesheet markdown entries are rendered with
+	MarkdownStylesheet *string            `json:"markdownStylesheet" form:"markdownStylesheet"`
+	Text               bool               `json:"text" form:"text"`
+	TextWords          bool               `json:"textWords" form:"textWords"`
+	Preview            *PdfPreviewOptions `json:"preview" form:"preview"`
 }
 
 type PdfResponse struct {
-	Url        string   `json:"url"`
-	Components []string `json:"components"`
+	Url        string               `json:"url"`
+	Components []string             `json:"components"`
+	Signature  *PdfSignatureDetails `json:"signature,omitempty"`
+	Info       *PdfInfo             `json:"info,omitempty"`
 }
 
 type PdfPreviewResponse struct {
-	Pages   int8     `json:"pages"`
-	Images  []string `json:"images"`
-	pdfInfo map[string]string
+	Pages    int              `json:"pages"`
+	Images   []string         `json:"images"`
+	Previews []PdfPreviewPage `json:"previews"`
+	Text     []PdfTextPage    `json:"text,omitempty"`
+	Info     *PdfInfo         `json:"info"`
 }
 
 type PdfReturn struct {
 	OutputFile  *os.File
 	OutputFiles []string
+	Signature   *PdfSignatureDetails
+	Info        *PdfInfo
+}
+
+// pdfRenderOptions controls how Chrome prints a data entry
+type pdfRenderOptions struct {
+	// FormFields turns the html form controls into pdf form fields
+	FormFields bool
+	// Continuous prints the entry on a single page as tall as its content
+	Continuous bool
+	// Selector prints only the element it matches
+	Selector *string
+	// MarkdownStylesheet styles the html of markdown entries
+	MarkdownStylesheet string
+	// Outline embeds bookmarks for the headings of the entry
+	Outline bool
 }
 
 type PdfStatus struct {
 	success bool
 	index   int
 	result  *[]byte
+	title   string
+	err     error
 }
 
 func getBrowserTargets(c *gin.Context) chromedp.Tasks {
@@ -84,6 +123,38 @@ func buildPdf(pdfRequestParams *PdfRequest, serverOptions *ServerOptions) (*PdfR
 		return nil, errors.New("parameter conversion error")
 	}
 
+	if pdfRequestParams.Encryption != nil {
+		if _, err := getEncryptionConfiguration(pdfRequestParams.Encryption); err != nil {
+			return nil, err
+		}
+	}
+
+	if pdfRequestParams.Separator != nil {
+		if _, err := getSeparatorTemplate(*pdfRequestParams.Separator); err != nil {
+			return nil, err
+		}
+	}
+
+	if pdfRequestParams.Imposition != nil {
+		if _, err := getImpositionConfiguration(pdfRequestParams.Imposition); err != nil {
+			return nil, err
+		}
+	}
+
+	if pdfRequestParams.Signature != nil {
+		if pdfRequestParams.Encryption != nil {
+			return nil, errors.New("a pdf can not be both signed and encrypted")
+		}
+
+		if _, err := getSigningIdentity(pdfRequestParams.Signature, serverOptions); err != nil {
+			return nil, err
+		}
+	}
+
+	if len(pdfRequestParams.Selectors) > len(requestData) {
+		return nil, fmt.Errorf("%d selectors for %d data entries", len(pdfRequestParams.Selectors), len(requestData))
+	}
+
 	// build context options
 	var opts []chromedp.ContextOption
 	opts = append(opts, chromedp.WithLogf(log.Printf))
@@ -93,30 +164,35 @@ func buildPdf(pdfRequestParams *PdfRequest, serverOptions *ServerOptions) (*PdfR
 		opts = append(opts, chromedp.WithDebugf(log.Printf))
 	}
 
-	channel := make(chan PdfStatus)
+	channel := make(chan PdfStatus, len(requestData))
 	for index, requestDataOrUrl := range requestData {
-		allocatorContext, _ := chromedp.NewRemoteAllocator(context.Background(), "ws://"+serverOptions.ChromeUri)
-
-		// create context
-		ctx, cancel := chromedp.NewContext(allocatorContext, opts...)
-
-		go chromedp.Run(ctx, printToPDF(requestDataOrUrl, printOptions, index, channel, cancel))
+		renderOptions := pdfRenderOptions{
+			FormFields:         pdfRequestParams.FormFields,
+			Continuous:         pdfRequestParams.Continuous,
+			Selector:           getEntrySelector(pdfRequestParams.Selectors, index),
+			MarkdownStylesheet: getMarkdownStylesheet(pdfRequestParams, serverOptions),
+		}
+		go buildPdfComponent(requestDataOrUrl, printOptions, renderOptions, index, channel, opts, serverOptions)
 	}
 
 	outputFiles := make(map[int]string)
+	titles := make([]string, len(requestData))
 
 	result := make([]PdfStatus, len(requestData))
 	for i := range result {
 		result[i] = <-channel
-		if result[i].success {
-			tempFile, err := os.CreateTemp(*serverOptions.DirectoryMap[DirectoryKeyPdf], fmt.Sprintf("%d-*.pdf", i))
-			if err != nil {
-				return nil, errors.New("unable to create output file")
-			}
+		if !result[i].success {
+			return nil, fmt.Errorf("unable to generate pdf for data entry %d: %w", result[i].index, result[i].err)
+		}
 
-			outputFiles[result[i].index] = tempFile.Name()
-			os.WriteFile(tempFile.Name(), *result[i].result, 0640)
+		tempFile, err := os.CreateTemp(*serverOptions.DirectoryMap[DirectoryKeyPdf], fmt.Sprintf("%d-*.pdf", i))
+		if err != nil {
+			return nil, errors.New("unable to create output file")
 		}
+
+		outputFiles[result[i].index] = tempFile.Name()
+		titles[result[i].index] = result[i].title
+		os.WriteFile(tempFile.Name(), *result[i].result, 0640)
 	}
 
 	keys := slices.Collect(maps.Keys(outputFiles))
@@ -126,16 +202,124 @@ func buildPdf(pdfRequestParams *PdfRequest, serverOptions *ServerOptions) (*PdfR
 		outputs[k] = outputFiles[k]
 	}
 
-	// Merge the PDF files
-	combinedFile, err := combinePdfs(outputs, serverOptions)
+	combinedFile, err := assembleCombinedPdf(pdfRequestParams, outputs, titles, printOptions, opts, serverOptions)
+	if err != nil {
+		return nil, err
+	}
+
+	// Imposition only applies to the combined pdf, the components keep their original pages
+	if pdfRequestParams.Imposition != nil {
+		if err := imposePdf(combinedFile.Name(), pdfRequestParams.Imposition); err != nil {
+			return nil, err
+		}
+	}
+
+	var signature *PdfSignatureDetails
+	if pdfRequestParams.Signature != nil {
+		signature, err = signPdf(combinedFile.Name(), pdfRequestParams.Signature, serverOptions)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// The encrypted pdf may need a password to be read, describe it before it is encrypted
+	pdfInfo, err := getPdfInfo(combinedFile.Name(), serverOptions)
+	if err != nil {
+		return nil, err
+	}
+
+	// Encryption has to happen last, nothing can modify the pdfs once they are encrypted
+	if pdfRequestParams.Encryption != nil {
+		for _, pdfFile := range append(outputs, combinedFile.Name()) {
+			if err := encryptPdf(pdfFile, pdfRequestParams.Encryption); err != nil {
+				return nil, err
+			}
+		}
+		pdfInfo.Encrypted = true
+	}
+
+	return &PdfReturn{OutputFile: combinedFile, OutputFiles: outputs, Signature: signature, Info: pdfInfo}, nil
+}
+
+// buildPdfComponent produces the pdf for a single data entry
+func buildPdfComponent(requestDataOrUrl string, printOptions *page.PrintToPDFParams, renderOptions pdfRenderOptions, index int, res chan PdfStatus, opts []chromedp.ContextOption, serverOptions *ServerOptions) {
+	var title string
+	pdfData, err := buildDataEntry(requestDataOrUrl, printOptions, renderOptions, opts, serverOptions, &title)
+	if err != nil {
+		res <- PdfStatus{false, index, nil, "", err}
+		return
+	}
+
+	res <- PdfStatus{true, index, &pdfData, title, nil}
+}
+
+// buildDataEntry returns the pdf for a data entry, entries that already are pdfs skip Chrome entirely
+func buildDataEntry(requestDataOrUrl string, printOptions *page.PrintToPDFParams, renderOptions pdfRenderOptions, opts []chromedp.ContextOption, serverOptions *ServerOptions, title *string) ([]byte, error) {
+	pdfData, isPdf, err := loadPdfSource(requestDataOrUrl, serverOptions)
+	if err != nil {
+		return nil, err
+	}
+
+	if isPdf {
+		if renderOptions.Selector != nil {
+			return nil, errors.New("a selector can not be applied to a pdf")
+		}
+
+		return pdfData, nil
+	}
+
+	// Markdown is rendered to html, its headings become the bookmarks of the entry
+	markdown, isMarkdown, err := loadMarkdownSource(requestDataOrUrl)
 	if err != nil {
-		return nil, errors.New("unable to combine component pdfs")
+		return nil, err
+	}
+
+	if isMarkdown {
+		requestDataOrUrl, err = renderMarkdown(markdown, renderOptions.MarkdownStylesheet)
+		if err != nil {
+			return nil, err
+		}
+		renderOptions.Outline = true
+	}
+
+	return renderPdf(requestDataOrUrl, printOptions, renderOptions, opts, serverOptions, title)
+}
+
+// renderPdf prints html or a url to pdf with Chrome, the document title is stored in title when it is not nil
+func renderPdf(requestDataOrUrl string, printOptions *page.PrintToPDFParams, renderOptions pdfRenderOptions, opts []chromedp.ContextOption, serverOptions *ServerOptions, title *string) ([]byte, error) {
+	serverOptions.TabPool.Acquire()
+	defer serverOptions.TabPool.Release()
+
+	allocatorContext, allocatorCancel := chromedp.NewRemoteAllocator(context.Background(), "ws://"+serverOptions.ChromeUri)
+	defer allocatorCancel()
+
+	// create context
+	ctx, cancel := chromedp.NewContext(allocatorContext, opts...)
+	defer cancel()
+
+	// Controls are only collected when they have to become form fields
+	var controls *[]htmlFormControl
+	if renderOptions.FormFields {
+		controls = &[]htmlFormControl{}
+	}
+
+	var pdfData []byte
+	if err := chromedp.Run(ctx, printToPDF(requestDataOrUrl, printOptions, renderOptions, &pdfData, title, controls)); err != nil {
+		return nil, err
+	}
+
+	if controls != nil {
+		return addFormFields(pdfData, *controls)
 	}
 
-	return &PdfReturn{OutputFile: combinedFile, OutputFiles: outputs}, nil
+	return pdfData, nil
 }
 
-func printToPDF(urlStr string, params *page.PrintToPDFParams, index int, res chan PdfStatus, cancelContext context.CancelFunc) chromedp.Tasks {
+func printToPDF(urlStr string, params *page.PrintToPDFParams, renderOptions pdfRenderOptions, res *[]byte, title *string, controls *[]htmlFormControl) chromedp.Tasks {
+	if res == nil {
+		panic("res cannot be nil")
+	}
+
 	var base64EncodedData string
 	match, _ := regexp.MatchString("(?i)^(https?|file|data):", urlStr)
 	if match {
@@ -144,20 +328,64 @@ func printToPDF(urlStr string, params *page.PrintToPDFParams, index int, res cha
 		base64EncodedData = "data:text/html;base64," + base64.StdEncoding.EncodeToString([]byte(urlStr))
 	}
 
-	return chromedp.Tasks{
+	tasks := chromedp.Tasks{
 		chromedp.Navigate(base64EncodedData),
+	}
+
+	if title != nil {
+		tasks = append(tasks, chromedp.Title(title))
+	}
+
+	// The element is isolated first so the form controls and the continuous page height match what is printed
+	if renderOptions.Selector != nil {
+		tasks = append(tasks, isolateElement(*renderOptions.Selector))
+	}
+
+	if controls != nil {
+		tasks = append(tasks, chromedp.Evaluate(getCollectFormControlsScript(), controls))
+	}
+
+	return append(tasks,
 		chromedp.ActionFunc(func(ctx context.Context) error {
-			buf, _, err := params.Do(ctx)
-			if err != nil {
-				res <- PdfStatus{false, index, nil}
-			} else {
-				res <- PdfStatus{true, index, &buf}
+			printParams := params
+			if renderOptions.Continuous {
+				var err error
+				printParams, err = getContinuousPrintOptions(ctx, params)
+				if err != nil {
+					return err
+				}
 			}
 
-			defer cancelContext()
-			return nil
+			if renderOptions.Outline {
+				outlineParams := *printParams
+				printParams = outlineParams.WithGenerateDocumentOutline(true)
+			}
+
+			buf, _, err := printParams.Do(ctx)
+
+			*res = buf
+
+			return err
 		}),
+	)
+}
+
+// getEntrySelector returns the selector of the data entry at index, or nil when the entry is printed in full
+func getEntrySelector(selectors []string, index int) *string {
+	if index >= len(selectors) || selectors[index] == "" {
+		return nil
+	}
+
+	return &selectors[index]
+}
+
+// getMarkdownStylesheet returns the stylesheet of the request, or the default one of the server
+func getMarkdownStylesheet(requestParams *PdfRequest, serverOptions *ServerOptions) string {
+	if requestParams.MarkdownStylesheet != nil {
+		return *requestParams.MarkdownStylesheet
 	}
+
+	return serverOptions.MarkdownStylesheet
 }
 
 func getPrintOptions(requestParams *PdfRequest, headerStyleTemplate *string) (*page.PrintToPDFParams, error) {
@@ -0,0 +1,36 @@
+package main
+
+import (
+	"encoding/base64"
+	"testing"
+)
+
+func TestGetEntrySelector(t *testing.T) {
+	selectors := []string{"#invoice", ""}
+
+	tests := map[int]string{0: "#invoice", 1: "", 2: ""}
+	for index, want := range tests {
+		selector := getEntrySelector(selectors, index)
+		if want == "" && selector != nil {
+			t.Errorf("entry %d has selector %q, want none", index, *selector)
+		}
+
+		if want != "" && (selector == nil || *selector != want) {
+			t.Errorf("entry %d has selector %v, want %q", index, selector, want)
+		}
+	}
+}
+
+func TestBuildDataEntrySelectorOnPdf(t *testing.T) {
+	selector := "#invoice"
+	source := "data:application/pdf;base64," + base64.StdEncoding.EncodeToString(newTestPdf(t, 1))
+	options := &ServerOptions{PdfEngine: NewPdfcpuEngine()}
+
+	if _, err := buildDataEntry(source, nil, pdfRenderOptions{Selector: &selector}, nil, options, nil); err == nil {
+		t.Error("expected an error for a selector on a pdf entry")
+	}
+
+	if _, err := buildDataEntry(source, nil, pdfRenderOptions{}, nil, options, nil); err != nil {
+		t.Errorf("the pdf entry failed without a selector: %s", err)
+	}
+}
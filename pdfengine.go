@@ -0,0 +1,161 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/pdfcpu/pdfcpu/pkg/api"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/model"
+)
+
+// PdfEngine handles the pdf manipulation that does not require rendering
+type PdfEngine interface {
+	Merge(inputFiles []string, outputFile string) error
+	Info(pdfFile string) (*PdfInfo, error)
+	Validate(pdf []byte) error
+	PageCount(pdf []byte) (int, error)
+	SelectPages(pdf []byte, pages []string) ([]byte, error)
+	CollectPages(pdf []byte, pages []string) ([]byte, error)
+	RemovePages(pdf []byte, pages []string) ([]byte, error)
+	RotatePages(pdf []byte, rotation int, pages []string) ([]byte, error)
+	// Split breaks pdf into documents of span pages, a span of 0 splits at the top level bookmarks
+	Split(pdf []byte, span int) ([][]byte, error)
+}
+
+// PdfRasterizer renders the pages of a pdf to images named after outputPrefix and returns the image of every page
+type PdfRasterizer interface {
+	Rasterize(pdfFile string, outputPrefix string, options RasterOptions) (map[int]string, error)
+}
+
+// PdfTextExtractor extracts the text of every page of a pdf
+type PdfTextExtractor interface {
+	Text(pdfFile string) ([]string, error)
+	// Words returns the size of every page and the box of every word on it, in points from the top left corner
+	Words(pdfFile string) ([]PdfTextLayout, error)
+}
+
+// PdfcpuEngine is the in process PdfEngine
+type PdfcpuEngine struct{}
+
+func NewPdfcpuEngine() *PdfcpuEngine {
+	return &PdfcpuEngine{}
+}
+
+func (engine *PdfcpuEngine) Merge(inputFiles []string, outputFile string) error {
+	conf := model.NewDefaultConfiguration()
+	conf.MergeBookmarkMode = model.MergeBookmarkModePreserve
+
+	return api.MergeCreateFile(inputFiles, outputFile, false, conf)
+}
+
+func (engine *PdfcpuEngine) Validate(pdf []byte) error {
+	return api.Validate(bytes.NewReader(pdf), model.NewDefaultConfiguration())
+}
+
+func (engine *PdfcpuEngine) PageCount(pdf []byte) (int, error) {
+	return api.PageCount(bytes.NewReader(pdf), model.NewDefaultConfiguration())
+}
+
+// SelectPages keeps the selected pages in document order
+func (engine *PdfcpuEngine) SelectPages(pdf []byte, pages []string) ([]byte, error) {
+	var output bytes.Buffer
+	err := api.Trim(bytes.NewReader(pdf), &output, pages, model.NewDefaultConfiguration())
+
+	return output.Bytes(), err
+}
+
+// CollectPages keeps the selected pages in the order they were selected, pages may be repeated
+func (engine *PdfcpuEngine) CollectPages(pdf []byte, pages []string) ([]byte, error) {
+	var output bytes.Buffer
+	err := api.Collect(bytes.NewReader(pdf), &output, pages, model.NewDefaultConfiguration())
+
+	return output.Bytes(), err
+}
+
+func (engine *PdfcpuEngine) RemovePages(pdf []byte, pages []string) ([]byte, error) {
+	var output bytes.Buffer
+	err := api.RemovePages(bytes.NewReader(pdf), &output, pages, model.NewDefaultConfiguration())
+
+	return output.Bytes(), err
+}
+
+func (engine *PdfcpuEngine) RotatePages(pdf []byte, rotation int, pages []string) ([]byte, error) {
+	var output bytes.Buffer
+	err := api.Rotate(bytes.NewReader(pdf), &output, rotation, pages, model.NewDefaultConfiguration())
+
+	return output.Bytes(), err
+}
+
+func (engine *PdfcpuEngine) Split(pdf []byte, span int) ([][]byte, error) {
+	pageSpans, err := api.SplitRaw(bytes.NewReader(pdf), span, model.NewDefaultConfiguration())
+	if err != nil {
+		return nil, err
+	}
+
+	var documents [][]byte
+	for _, pageSpan := range pageSpans {
+		document, err := io.ReadAll(pageSpan.Reader)
+		if err != nil {
+			return nil, err
+		}
+		documents = append(documents, document)
+	}
+
+	return documents, nil
+}
+
+// Info returns the document information, fonts included
+func (engine *PdfcpuEngine) Info(pdfFile string) (*PdfInfo, error) {
+	file, err := os.Open(pdfFile)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	pdfInfo, err := api.PDFInfo(file, filepath.Base(pdfFile), nil, true, model.NewDefaultConfiguration())
+	if err != nil {
+		return nil, err
+	}
+
+	return newPdfInfo(pdfInfo), nil
+}
+
+func getPdfInfo(pdfFile string, options *ServerOptions) (*PdfInfo, error) {
+	info, err := options.PdfEngine.Info(pdfFile)
+	if err != nil {
+		return nil, fmt.Errorf("unable to get pdf information: %w", err)
+	}
+
+	return info, nil
+}
+
+func getPdfPageCount(pdfFile string, serverOptions *ServerOptions) (int, error) {
+	pdfData, err := os.ReadFile(pdfFile)
+	if err != nil {
+		return 0, err
+	}
+
+	pageCount, err := serverOptions.PdfEngine.PageCount(pdfData)
+	if err != nil {
+		return 0, fmt.Errorf("unable to read page count of %s: %w", filepath.Base(pdfFile), err)
+	}
+
+	return pageCount, nil
+}
+
+func combinePdfs(inputFiles []string, options *ServerOptions) (*os.File, error) {
+	// Merge the PDF files
+	combinedFile, err := os.CreateTemp(*options.DirectoryMap[DirectoryKeyPdf], "*-combined.pdf")
+	if err != nil {
+		return nil, fmt.Errorf("unable to create combined pdf output files: %w", err)
+	}
+
+	if err := options.PdfEngine.Merge(inputFiles, combinedFile.Name()); err != nil {
+		return nil, fmt.Errorf("unable to combine pdfs: %w", err)
+	}
+
+	return combinedFile, nil
+}
@@ -0,0 +1,240 @@
+package main
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+var dataUriPattern = regexp.MustCompile(`(?is)^data:([^,;]*)((?:;[^,]*)?),(.*)$`)
+var pdfDataUriPattern = regexp.MustCompile(`(?is)^data:application/pdf[;,]`)
+var httpUrlPattern = regexp.MustCompile(`(?i)^https?:`)
+var whitespacePattern = regexp.MustCompile(`\s+`)
+
+// pdfPrefix starts every pdf file, base64PdfPrefix is its base64 encoding
+const (
+	pdfPrefix       = "%PDF-"
+	base64PdfPrefix = "JVBERi0"
+)
+
+var (
+	ErrSourceTooLarge    = errors.New("the pdf or image is too large")
+	ErrFetchUrlsDisabled = errors.New("fetching urls is disabled on this server")
+)
+
+// PdfSource identifies an existing pdf, either one in the pdfs directory or one supplied with the request as an
+// "upload" file, base64 content or a url
+type PdfSource struct {
+	File string `json:"file" form:"file"`
+	Data string `json:"data" form:"data"`
+}
+
+func readPdfSource(c *gin.Context, source *PdfSource, options *ServerOptions) ([]byte, error) {
+	if upload, err := c.FormFile("upload"); err == nil {
+		file, err := upload.Open()
+		if err != nil {
+			return nil, fmt.Errorf("unable to read uploaded pdf: %w", err)
+		}
+		defer file.Close()
+
+		pdfData, err := readLimited(file, options.MaxSourceSize)
+		if err != nil {
+			return nil, fmt.Errorf("unable to read uploaded pdf: %w", err)
+		}
+
+		if err := options.PdfEngine.Validate(pdfData); err != nil {
+			return nil, fmt.Errorf("invalid pdf: %w", err)
+		}
+
+		return pdfData, nil
+	}
+
+	if source.File != "" {
+		// Only files in the pdfs directory may be used
+		pdfFile := filepath.Join(*options.DirectoryMap[DirectoryKeyPdf], filepath.Base(source.File))
+		pdfData, err := os.ReadFile(pdfFile)
+		if err != nil {
+			return nil, fmt.Errorf("unable to read %s", filepath.Base(source.File))
+		}
+
+		if err := options.PdfEngine.Validate(pdfData); err != nil {
+			return nil, fmt.Errorf("invalid pdf: %w", err)
+		}
+
+		return pdfData, nil
+	}
+
+	if source.Data != "" {
+		// The source has to be a pdf, so a url is downloaded without checking what it serves first
+		if trimmed := strings.TrimSpace(source.Data); httpUrlPattern.MatchString(trimmed) {
+			if !options.FetchUrls {
+				return nil, ErrFetchUrlsDisabled
+			}
+
+			pdfData, err := fetchUrl(trimmed, options.MaxSourceSize)
+			if err != nil {
+				return nil, err
+			}
+
+			if err := options.PdfEngine.Validate(pdfData); err != nil {
+				return nil, fmt.Errorf("invalid pdf: %w", err)
+			}
+
+			return pdfData, nil
+		}
+
+		pdfData, isPdf, err := loadPdfSource(source.Data, options)
+		if err != nil {
+			return nil, err
+		}
+
+		if !isPdf {
+			return nil, errors.New("data is not a pdf")
+		}
+
+		return pdfData, nil
+	}
+
+	return nil, errors.New("one of file, data or an upload is required")
+}
+
+// loadPdfSource returns the pdf for data entries that are already pdfs, either base64 content, a
+// data:application/pdf uri or, when the server fetches urls, a pdf url. isPdf is false for entries that need rendering.
+func loadPdfSource(requestDataOrUrl string, options *ServerOptions) (pdfData []byte, isPdf bool, err error) {
+	trimmed := strings.TrimSpace(requestDataOrUrl)
+
+	switch {
+	case pdfDataUriPattern.MatchString(trimmed):
+		_, pdfData, err = decodeDataUri(trimmed)
+	case strings.HasPrefix(trimmed, base64PdfPrefix):
+		pdfData, err = decodeBase64(trimmed)
+	case httpUrlPattern.MatchString(trimmed) && options.FetchUrls:
+		pdfData, isPdf, err = fetchPdf(trimmed, options.MaxSourceSize)
+		if err != nil || !isPdf {
+			return nil, isPdf, err
+		}
+	default:
+		return nil, false, nil
+	}
+
+	if err != nil {
+		return nil, true, fmt.Errorf("unable to decode pdf: %w", err)
+	}
+
+	if err := options.PdfEngine.Validate(pdfData); err != nil {
+		return nil, true, fmt.Errorf("invalid pdf: %w", err)
+	}
+
+	return pdfData, true, nil
+}
+
+// decodeDataUri returns the media type and the content of a data uri, either base64 or percent-encoded
+func decodeDataUri(dataUri string) (string, []byte, error) {
+	matches := dataUriPattern.FindStringSubmatch(dataUri)
+	if matches == nil {
+		return "", nil, errors.New("invalid data uri")
+	}
+
+	mediaType := strings.ToLower(matches[1])
+	if strings.Contains(strings.ToLower(matches[2]), ";base64") {
+		data, err := decodeBase64(matches[3])
+		return mediaType, data, err
+	}
+
+	unescaped, err := url.PathUnescape(matches[3])
+	return mediaType, []byte(unescaped), err
+}
+
+func decodeBase64(data string) ([]byte, error) {
+	return base64.StdEncoding.DecodeString(whitespacePattern.ReplaceAllString(data, ""))
+}
+
+// fetchPdf downloads pdfUrl when it is a pdf, other urls are left for Chrome to navigate so that the pages are only
+// requested once, by Chrome
+func fetchPdf(pdfUrl string, maxSize int64) ([]byte, bool, error) {
+	if !isPdfUrl(pdfUrl) {
+		return nil, false, nil
+	}
+
+	pdfData, err := fetchUrl(pdfUrl, maxSize)
+	if err != nil {
+		return nil, true, err
+	}
+
+	return pdfData, true, nil
+}
+
+// isPdfUrl reports whether the path of pdfUrl ends in .pdf, or a HEAD request of it answers with the pdf content type
+func isPdfUrl(pdfUrl string) bool {
+	parsedUrl, err := url.Parse(pdfUrl)
+	if err == nil && strings.EqualFold(path.Ext(parsedUrl.Path), ".pdf") {
+		return true
+	}
+
+	client := http.Client{Timeout: 60 * time.Second}
+	response, err := client.Head(pdfUrl)
+	if err != nil {
+		// Let Chrome report on urls we can't reach
+		return false
+	}
+	response.Body.Close()
+
+	return response.StatusCode == http.StatusOK && isPdfContentType(response.Header.Get("Content-Type"))
+}
+
+// fetchUrl downloads sourceUrl, failing on anything but a 200 response
+func fetchUrl(sourceUrl string, maxSize int64) ([]byte, error) {
+	response, err := openUrl(sourceUrl)
+	if err != nil {
+		return nil, fmt.Errorf("unable to fetch %s: %w", sourceUrl, err)
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unable to fetch %s: %s", sourceUrl, response.Status)
+	}
+
+	data, err := readLimited(response.Body, maxSize)
+	if err != nil {
+		return nil, fmt.Errorf("unable to fetch %s: %w", sourceUrl, err)
+	}
+
+	return data, nil
+}
+
+func openUrl(sourceUrl string) (*http.Response, error) {
+	client := http.Client{Timeout: 60 * time.Second}
+
+	return client.Get(sourceUrl)
+}
+
+// readLimited reads reader to the end, failing with ErrSourceTooLarge past maxSize bytes
+func readLimited(reader io.Reader, maxSize int64) ([]byte, error) {
+	data, err := io.ReadAll(io.LimitReader(reader, maxSize+1))
+	if err != nil {
+		return nil, err
+	}
+
+	if int64(len(data)) > maxSize {
+		return nil, fmt.Errorf("%w, the limit is %d bytes", ErrSourceTooLarge, maxSize)
+	}
+
+	return data, nil
+}
+
+func isPdfContentType(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	return err == nil && mediaType == "application/pdf"
+}
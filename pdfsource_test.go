@@ -0,0 +1,96 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestLoadPdfSourceUrls(t *testing.T) {
+	pdf := newTestPdf(t, 1)
+
+	var requests []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests = append(requests, r.Method+" "+r.URL.Path)
+		if r.URL.Path == "/page" {
+			w.Header().Set("Content-Type", "text/html")
+			w.Write([]byte("<p>Hello</p>"))
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/pdf")
+		w.Write(pdf)
+	}))
+	defer server.Close()
+
+	tests := []struct {
+		name      string
+		path      string
+		fetchUrls bool
+		isPdf     bool
+		requests  []string
+	}{
+		{name: "page is left for chrome", path: "/page", fetchUrls: true, requests: []string{"HEAD /page"}},
+		{name: "pdf content type", path: "/document", fetchUrls: true, isPdf: true, requests: []string{"HEAD /document", "GET /document"}},
+		{name: "pdf extension", path: "/document.pdf", fetchUrls: true, isPdf: true, requests: []string{"GET /document.pdf"}},
+		{name: "fetching disabled", path: "/document.pdf"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			requests = nil
+			options := &ServerOptions{PdfEngine: NewPdfcpuEngine(), MaxSourceSize: 1 << 20, FetchUrls: test.fetchUrls}
+
+			pdfData, isPdf, err := loadPdfSource(server.URL+test.path, options)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if isPdf != test.isPdf || (isPdf && len(pdfData) != len(pdf)) {
+				t.Errorf("isPdf = %t with %d bytes, want %t", isPdf, len(pdfData), test.isPdf)
+			}
+
+			if !slices.Equal(requests, test.requests) {
+				t.Errorf("requests = %v, want %v", requests, test.requests)
+			}
+		})
+	}
+}
+
+func TestReadLimited(t *testing.T) {
+	pdf := newTestPdf(t, 1)
+
+	if _, err := readLimited(bytes.NewReader(pdf), int64(len(pdf))); err != nil {
+		t.Errorf("a source of exactly the limit failed: %s", err)
+	}
+
+	if _, err := readLimited(bytes.NewReader(pdf), int64(len(pdf)-1)); !errors.Is(err, ErrSourceTooLarge) {
+		t.Errorf("err = %v, want ErrSourceTooLarge", err)
+	}
+}
+
+func TestReadPdfSourceFile(t *testing.T) {
+	directory := t.TempDir()
+	options := &ServerOptions{DirectoryMap: map[string]*string{DirectoryKeyPdf: &directory}, PdfEngine: NewPdfcpuEngine()}
+
+	os.WriteFile(filepath.Join(directory, "valid.pdf"), newTestPdf(t, 1), 0640)
+	os.WriteFile(filepath.Join(directory, "broken.pdf"), []byte("%PDF-1.7 broken"), 0640)
+
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request = httptest.NewRequest(http.MethodPost, "/pdf/preview", nil)
+
+	if _, err := readPdfSource(c, &PdfSource{File: "valid.pdf"}, options); err != nil {
+		t.Errorf("the valid pdf failed: %s", err)
+	}
+
+	if _, err := readPdfSource(c, &PdfSource{File: "broken.pdf"}, options); err == nil {
+		t.Error("expected an error for the broken pdf")
+	}
+}
@@ -5,11 +5,16 @@ import (
 	"encoding/base64"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"log"
 	"os"
+	"path/filepath"
 	"regexp"
+	"slices"
 
+	"github.com/chromedp/cdproto/emulation"
 	"github.com/chromedp/cdproto/page"
+	"github.com/chromedp/cdproto/runtime"
 	"github.com/chromedp/chromedp"
 )
 
@@ -21,9 +26,67 @@ type PngRequest struct {
 	Width    *float32 `json:"width" form:"width"`
 	Height   *float32 `json:"height" form:"height"`
 	Scale    *float32 `json:"scale" form:"scale"`
+	Selector *string  `json:"selector" form:"selector"`
+	Padding  float64  `json:"padding" form:"padding"`
+	// Format is png (default), jpeg or webp, quality applies to jpeg and webp
+	Format  string `json:"format" form:"format"`
+	Quality *int   `json:"quality" form:"quality"`
+	// Viewports captures the page once per viewport, form submissions provide them as a json string
+	Viewports []PngViewport `json:"viewports" form:"-"`
 }
 
-func buildPng(pngRequestParams *PngRequest, serverOptions *ServerOptions) (*os.File, error) {
+// PngViewport is a named browser window size, a DeviceScaleFactor of 0 keeps the browser default
+type PngViewport struct {
+	Name              string  `json:"name"`
+	Width             int64   `json:"width"`
+	Height            int64   `json:"height"`
+	DeviceScaleFactor float64 `json:"deviceScaleFactor"`
+	Mobile            bool    `json:"mobile"`
+}
+
+// pngCapture is an image file and the name of the viewport it was captured in
+type pngCapture struct {
+	Viewport string
+	File     *os.File
+}
+
+const (
+	maxViewportSize      = 8192
+	maxDeviceScaleFactor = 4
+	// settleViewportScript waits for the page to lay out and paint after a resize
+	settleViewportScript = `new Promise(function (resolve) { requestAnimationFrame(function () { requestAnimationFrame(resolve); }); })`
+)
+
+// ErrNoElementMatched is returned when a selector matches no visible element of a screenshot or a pdf data entry
+var ErrNoElementMatched = errors.New("the selector matches no visible element")
+
+// elementBoxesScript returns the box of every visible element matching a selector, in page coordinates
+const elementBoxesScript = `(function (selector, padding) {
+	return Array.from(document.querySelectorAll(selector)).map(function (element) {
+		var box = element.getBoundingClientRect();
+		var x = Math.max(0, box.left + window.scrollX - padding);
+		var y = Math.max(0, box.top + window.scrollY - padding);
+		return {
+			x: x,
+			y: y,
+			width: box.left + window.scrollX + box.width + padding - x,
+			height: box.top + window.scrollY + box.height + padding - y,
+			visible: box.width > 0 && box.height > 0
+		};
+	}).filter(function (box) {
+		return box.visible;
+	});
+})(%s, %s)`
+
+type elementBox struct {
+	X      float64 `json:"x"`
+	Y      float64 `json:"y"`
+	Width  float64 `json:"width"`
+	Height float64 `json:"height"`
+}
+
+// buildPng captures the page, or every element matching the selector, in every viewport and returns the image files
+func buildPng(pngRequestParams *PngRequest, serverOptions *ServerOptions) ([]pngCapture, error) {
 	requestData := pngRequestParams.Data
 	if serverOptions.DebugSources {
 		tempFile, err := os.CreateTemp(*serverOptions.DirectoryMap[DirectoryKeySources], "*.html")
@@ -44,6 +107,10 @@ func buildPng(pngRequestParams *PngRequest, serverOptions *ServerOptions) (*os.F
 		return nil, err
 	}
 
+	if err := validateViewports(pngRequestParams.Viewports); err != nil {
+		return nil, err
+	}
+
 	var base64EncodedData string
 	match, _ := regexp.MatchString("(?i)^(https?|file|data):", pngRequestParams.Data)
 	if match {
@@ -61,37 +128,179 @@ func buildPng(pngRequestParams *PngRequest, serverOptions *ServerOptions) (*os.F
 		opts = append(opts, chromedp.WithDebugf(log.Printf))
 	}
 
+	serverOptions.TabPool.Acquire()
+	defer serverOptions.TabPool.Release()
+
 	allocatorContext, _ := chromedp.NewRemoteAllocator(context.Background(), "ws://"+serverOptions.ChromeUri)
 
 	// create context
 	ctx, cancel := chromedp.NewContext(allocatorContext, opts...)
 	defer cancel()
 
-	var screenshotBuffer []byte
-	err = chromedp.Run(ctx,
-		chromedp.Navigate(base64EncodedData),
-		printToPng(&screenshotBuffer, printOptions),
-	)
-
-	if err != nil {
+	if err := chromedp.Run(ctx, chromedp.Navigate(base64EncodedData)); err != nil {
 		return nil, err
 	}
 
-	sz := len(screenshotBuffer)
-	log.Printf("Screenshot Buffer Length %d", sz)
+	if len(pngRequestParams.Viewports) == 0 {
+		outputFiles, err := capturePng(ctx, pngRequestParams, printOptions, serverOptions)
+		if err != nil {
+			return nil, err
+		}
 
-	if sz <= 0 {
-		return nil, errors.New("no image returned")
+		return newPngCaptures("", outputFiles), nil
 	}
 
-	tempFile, err := os.CreateTemp(*serverOptions.DirectoryMap[DirectoryKeyPng], "*.png")
-	if err != nil {
-		return nil, errors.New("unable to create output file")
+	// The page is loaded once and resized for every viewport
+	var captures []pngCapture
+	for _, viewport := range pngRequestParams.Viewports {
+		err := chromedp.Run(ctx,
+			emulation.SetDeviceMetricsOverride(viewport.Width, viewport.Height, viewport.DeviceScaleFactor, viewport.Mobile),
+			chromedp.Evaluate(settleViewportScript, nil, func(p *runtime.EvaluateParams) *runtime.EvaluateParams {
+				return p.WithAwaitPromise(true)
+			}),
+		)
+		if err != nil {
+			return nil, fmt.Errorf("unable to resize to viewport %s: %w", viewport.Name, err)
+		}
+
+		outputFiles, err := capturePng(ctx, pngRequestParams, printOptions, serverOptions)
+		if err != nil {
+			return nil, err
+		}
+
+		captures = append(captures, newPngCaptures(viewport.Name, outputFiles)...)
+	}
+
+	return captures, nil
+}
+
+// newPngResponse lists the captured images under url, the first image is also returned on its own
+func newPngResponse(captures []pngCapture, format string, url string) *PngResponse {
+	response := &PngResponse{Format: format}
+	for _, capture := range captures {
+		imageUrl := url + filepath.Base(capture.File.Name())
+		response.Images = append(response.Images, imageUrl)
+
+		if capture.Viewport != "" {
+			if response.Viewports == nil {
+				response.Viewports = make(map[string][]string)
+			}
+			response.Viewports[capture.Viewport] = append(response.Viewports[capture.Viewport], imageUrl)
+		}
+	}
+
+	response.Png = filepath.Base(captures[0].File.Name())
+	response.Url = url + response.Png
+
+	return response
+}
+
+func newPngCaptures(viewport string, outputFiles []*os.File) []pngCapture {
+	captures := make([]pngCapture, len(outputFiles))
+	for index, outputFile := range outputFiles {
+		captures[index] = pngCapture{Viewport: viewport, File: outputFile}
+	}
+
+	return captures
+}
+
+// capturePng captures the loaded page, or every element matching the selector, and writes the image files
+func capturePng(ctx context.Context, pngRequestParams *PngRequest, printOptions *page.CaptureScreenshotParams, serverOptions *ServerOptions) ([]*os.File, error) {
+	captures := []*page.CaptureScreenshotParams{printOptions}
+	if pngRequestParams.Selector != nil {
+		var err error
+		captures, err = getElementScreenshotOptions(ctx, printOptions, *pngRequestParams.Selector, pngRequestParams.Padding, pngRequestParams.Scale)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	var outputFiles []*os.File
+	for _, captureOptions := range captures {
+		var screenshotBuffer []byte
+		if err := chromedp.Run(ctx, printToPng(&screenshotBuffer, captureOptions)); err != nil {
+			return nil, err
+		}
+
+		sz := len(screenshotBuffer)
+		log.Printf("Screenshot Buffer Length %d", sz)
+
+		if sz <= 0 {
+			return nil, errors.New("no image returned")
+		}
+
+		tempFile, err := os.CreateTemp(*serverOptions.DirectoryMap[DirectoryKeyPng], "*."+previewExtensions[pngRequestParams.Format])
+		if err != nil {
+			return nil, errors.New("unable to create output file")
+		}
+
+		os.WriteFile(tempFile.Name(), screenshotBuffer, 0640)
+		outputFiles = append(outputFiles, tempFile)
 	}
 
-	os.WriteFile(tempFile.Name(), screenshotBuffer, 0640)
+	return outputFiles, nil
+}
 
-	return tempFile, nil
+// validateViewports checks the viewports of a request
+func validateViewports(viewports []PngViewport) error {
+	var names []string
+	for _, viewport := range viewports {
+		if !previewSizeNamePattern.MatchString(viewport.Name) {
+			return fmt.Errorf("invalid viewport name %q, use letters, digits and underscores", viewport.Name)
+		}
+
+		if slices.Contains(names, viewport.Name) {
+			return fmt.Errorf("viewport %q is requested more than once", viewport.Name)
+		}
+		names = append(names, viewport.Name)
+
+		if viewport.Width < 1 || viewport.Width > maxViewportSize || viewport.Height < 1 || viewport.Height > maxViewportSize {
+			return fmt.Errorf("viewport %q must be between 1 and %d pixels wide and high", viewport.Name, maxViewportSize)
+		}
+
+		if viewport.DeviceScaleFactor < 0 || viewport.DeviceScaleFactor > maxDeviceScaleFactor {
+			return fmt.Errorf("viewport %q device scale factor must be between 0 and %d", viewport.Name, maxDeviceScaleFactor)
+		}
+	}
+
+	return nil
+}
+
+// getElementScreenshotOptions returns capture options clipped to every visible element matching selector
+func getElementScreenshotOptions(ctx context.Context, printOptions *page.CaptureScreenshotParams, selector string, padding float64, scale *float32) ([]*page.CaptureScreenshotParams, error) {
+	if selector == "" {
+		return nil, errors.New("selector is empty")
+	}
+
+	if padding < 0 {
+		return nil, errors.New("padding must be positive")
+	}
+
+	encodedSelector, _ := json.Marshal(selector)
+	encodedPadding, _ := json.Marshal(padding)
+
+	var boxes []elementBox
+	if err := chromedp.Run(ctx, chromedp.Evaluate(fmt.Sprintf(elementBoxesScript, encodedSelector, encodedPadding), &boxes)); err != nil {
+		return nil, fmt.Errorf("invalid selector %q: %w", selector, err)
+	}
+
+	if len(boxes) == 0 {
+		return nil, fmt.Errorf("%w: %s", ErrNoElementMatched, selector)
+	}
+
+	clipScale := 1.0
+	if scale != nil {
+		clipScale = float64(*scale)
+	}
+
+	captures := make([]*page.CaptureScreenshotParams, len(boxes))
+	for index, box := range boxes {
+		captureOptions := *printOptions
+		captureOptions.Clip = &page.Viewport{X: box.X, Y: box.Y, Width: box.Width, Height: box.Height, Scale: clipScale}
+		captures[index] = &captureOptions
+	}
+
+	return captures, nil
 }
 
 func printToPng(res *[]byte, params *page.CaptureScreenshotParams) chromedp.Action {
@@ -108,15 +317,42 @@ func printToPng(res *[]byte, params *page.CaptureScreenshotParams) chromedp.Acti
 	})
 }
 
+// getScreenshotOptions returns the capture options of a request and defaults its format to png
 func getScreenshotOptions(requestParams *PngRequest) (*page.CaptureScreenshotParams, error) {
 	params := page.CaptureScreenshot()
-	params.Format = page.CaptureScreenshotFormatPng
+	switch requestParams.Format {
+	case "", PreviewFormatPng:
+		requestParams.Format = PreviewFormatPng
+		params.Format = page.CaptureScreenshotFormatPng
+	case PreviewFormatJpeg:
+		params.Format = page.CaptureScreenshotFormatJpeg
+	case PreviewFormatWebp:
+		params.Format = page.CaptureScreenshotFormatWebp
+	default:
+		return nil, fmt.Errorf("invalid screenshot format %q, expected png, jpeg or webp", requestParams.Format)
+	}
+
+	if requestParams.Quality != nil {
+		if *requestParams.Quality < 0 || *requestParams.Quality > 100 {
+			return nil, errors.New("quality must be between 0 and 100")
+		}
+
+		if requestParams.Format == PreviewFormatPng {
+			return nil, errors.New("quality only applies to jpeg and webp screenshots")
+		}
+
+		params.Quality = int64(*requestParams.Quality)
+	}
+
 	params.CaptureBeyondViewport = true
 	params.FromSurface = true
-	if requestParams.X != nil || requestParams.Y != nil || requestParams.Width != nil || requestParams.Height != nil {
-		params.Clip = &page.Viewport{X: 0, Y: 0, Width: 1024.0, Height: 150.0, Scale: 1}
+	// Without an area the whole page is captured, captures of a selector are scaled by getElementScreenshotOptions
+	if requestParams.X == nil && requestParams.Y == nil && requestParams.Width == nil && requestParams.Height == nil {
+		return params, nil
 	}
 
+	params.Clip = &page.Viewport{X: 0, Y: 0, Width: 1024.0, Height: 150.0, Scale: 1}
+
 	if requestParams.X != nil {
 		params.Clip.X = float64(*requestParams.X)
 	}
@@ -0,0 +1,48 @@
+package main
+
+import "testing"
+
+func TestGetScreenshotOptions(t *testing.T) {
+	scale := float32(2)
+	width := float32(300)
+
+	tests := []struct {
+		name    string
+		request PngRequest
+		clip    bool
+		scale   float64
+	}{
+		{name: "whole page", request: PngRequest{}},
+		{name: "scale without a clip", request: PngRequest{Scale: &scale}},
+		{name: "clip", request: PngRequest{Width: &width}, clip: true, scale: 1},
+		{name: "scaled clip", request: PngRequest{Width: &width, Scale: &scale}, clip: true, scale: 2},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			params, err := getScreenshotOptions(&test.request)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if test.request.Format != PreviewFormatPng {
+				t.Errorf("format = %q, want the png default", test.request.Format)
+			}
+
+			if !test.clip {
+				if params.Clip != nil {
+					t.Errorf("clip = %+v, want the whole page", params.Clip)
+				}
+				return
+			}
+
+			if params.Clip == nil {
+				t.Fatal("the clip is missing")
+			}
+
+			if params.Clip.Width != float64(width) || params.Clip.Scale != test.scale {
+				t.Errorf("clip = %+v, want %v wide at scale %v", params.Clip, width, test.scale)
+			}
+		})
+	}
+}
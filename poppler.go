@@ -1,67 +1,224 @@
 package main
 
 import (
-	"errors"
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
 	"os"
 	"os/exec"
 	"path/filepath"
 	"regexp"
+	"strconv"
 	"strings"
 )
 
-func getPdfInfo(pdfFile string) (map[string]string, error) {
-	info := make(map[string]string)
+// Previews are scaled so their longest side is previewScaleTo pixels
+const previewScaleTo = 1024
 
-	cmd := exec.Command("/usr/bin/pdfinfo", pdfFile)
-	output, err := cmd.Output()
+// PopplerRasterizer renders previews with pdftocairo from poppler-utils
+type PopplerRasterizer struct {
+	Path string
+}
+
+// NewPopplerRasterizer returns nil when pdftocairo can not be found in binDirectory
+func NewPopplerRasterizer(binDirectory string) *PopplerRasterizer {
+	path := filepath.Join(binDirectory, "pdftocairo")
+	if !pathExists(path) {
+		return nil
+	}
+
+	return &PopplerRasterizer{Path: path}
+}
+
+func (rasterizer *PopplerRasterizer) Rasterize(pdfFile string, outputPrefix string, options RasterOptions) (map[int]string, error) {
+	var cmdArgs []string
+	extension := "jpg"
+	switch options.Format {
+	case PreviewFormatPng:
+		cmdArgs = append(cmdArgs, "-png")
+		extension = "png"
+	default:
+		cmdArgs = append(cmdArgs, "-jpeg")
+		if options.Quality > 0 {
+			cmdArgs = append(cmdArgs, "-jpegopt", fmt.Sprintf("quality=%d", options.Quality))
+		}
+	}
+
+	switch {
+	case options.Dpi > 0:
+		cmdArgs = append(cmdArgs, "-r", strconv.Itoa(options.Dpi))
+	case options.Width > 0:
+		cmdArgs = append(cmdArgs, "-scale-to-x", strconv.Itoa(options.Width), "-scale-to-y", "-1")
+	default:
+		cmdArgs = append(cmdArgs, "-scale-to", strconv.Itoa(previewScaleTo))
+	}
+
+	if options.FirstPage > 0 {
+		cmdArgs = append(cmdArgs, "-f", strconv.Itoa(options.FirstPage))
+	}
+	if options.LastPage > 0 {
+		cmdArgs = append(cmdArgs, "-l", strconv.Itoa(options.LastPage))
+	}
+
+	cmdArgs = append(cmdArgs, pdfFile)
+	cmdArgs = append(cmdArgs, outputPrefix)
+
+	if err := runCommand(rasterizer.Path, cmdArgs...); err != nil {
+		return nil, err
+	}
+
+	return rasterizedPages(outputPrefix, extension, options)
+}
+
+// rasterizedPages finds the images pdftocairo wrote, it pads the page numbers in their names with zeros to the number
+// of digits of the last page of the document
+func rasterizedPages(outputPrefix string, extension string, options RasterOptions) (map[int]string, error) {
+	pattern := regexp.MustCompile(`^` + regexp.QuoteMeta(filepath.Base(outputPrefix)) + `-(\d+)\.` + extension + `$`)
+
+	entries, err := os.ReadDir(filepath.Dir(outputPrefix))
 	if err != nil {
-		return nil, errors.New("unable to get pdf information")
+		return nil, err
 	}
 
-	myString := string(output)
-	lines := strings.Split(myString, "\n")
-	m1 := regexp.MustCompile(`/(^:)|(:$)/`)
+	pages := make(map[int]string)
+	for _, entry := range entries {
+		matches := pattern.FindStringSubmatch(entry.Name())
+		if matches == nil {
+			continue
+		}
 
-	for _, line := range lines {
-		cleanLine := m1.ReplaceAllString(line, "")
-		cols := strings.Split(cleanLine, ":")
-		if len(cols) == 2 {
-			info[strings.ToLower(strings.Replace(cols[0], " ", "_", -1))] = strings.TrimSpace(cols[1])
+		pageNumber, _ := strconv.Atoi(matches[1])
+		if (options.FirstPage > 0 && pageNumber < options.FirstPage) || (options.LastPage > 0 && pageNumber > options.LastPage) {
+			continue
 		}
+
+		pages[pageNumber] = filepath.Join(filepath.Dir(outputPrefix), entry.Name())
 	}
 
-	return info, nil
+	return pages, nil
 }
 
-func createPreviews(pdfFile string, outputDir string) (string, error) {
-	baseName := fileNameWithoutExtension(filepath.Base(pdfFile))
-	var cmdArgs []string
-	cmdArgs = append(cmdArgs, "-jpeg")
-	cmdArgs = append(cmdArgs, "-scale-to")
-	cmdArgs = append(cmdArgs, "1024")
-	cmdArgs = append(cmdArgs, pdfFile)
-	cmdArgs = append(cmdArgs, filepath.Join(outputDir, baseName))
-	cmd := exec.Command("/usr/bin/pdftocairo", cmdArgs...)
-	if err := cmd.Run(); err != nil {
-		return "", errors.New("unable to produce pdf image previews")
+// PopplerTextExtractor extracts text with pdftotext from poppler-utils
+type PopplerTextExtractor struct {
+	Path string
+}
+
+// NewPopplerTextExtractor returns nil when pdftotext can not be found in binDirectory
+func NewPopplerTextExtractor(binDirectory string) *PopplerTextExtractor {
+	path := filepath.Join(binDirectory, "pdftotext")
+	if !pathExists(path) {
+		return nil
 	}
 
-	return baseName, nil
+	return &PopplerTextExtractor{Path: path}
 }
 
-func combinePdfs(inputFiles []string, options *ServerOptions) (*os.File, error) {
-	// Merge the PDF files
-	combinedFile, err := os.CreateTemp(*options.DirectoryMap[DirectoryKeyPdf], "*-combined.pdf")
+func (extractor *PopplerTextExtractor) Text(pdfFile string) ([]string, error) {
+	output, err := runCommandOutput(extractor.Path, "-enc", "UTF-8", pdfFile, "-")
 	if err != nil {
-		return nil, errors.New("unable to create combined pdf output files")
+		return nil, err
 	}
 
-	cmdArgs := inputFiles
-	cmdArgs = append(cmdArgs, combinedFile.Name())
-	cmd := exec.Command("/usr/bin/pdfunite", cmdArgs...)
+	// Every page, the last one included, ends with a form feed
+	pages := strings.Split(string(output), "\f")
+	return pages[:len(pages)-1], nil
+}
+
+func (extractor *PopplerTextExtractor) Words(pdfFile string) ([]PdfTextLayout, error) {
+	output, err := runCommandOutput(extractor.Path, "-bbox", "-enc", "UTF-8", pdfFile, "-")
+	if err != nil {
+		return nil, err
+	}
+
+	return parseBoundingBoxes(output)
+}
+
+// parseBoundingBoxes reads the xhtml pdftotext -bbox writes, a page element per page with a word element per word
+func parseBoundingBoxes(output []byte) ([]PdfTextLayout, error) {
+	decoder := xml.NewDecoder(bytes.NewReader(output))
+	decoder.Strict = false
+	decoder.AutoClose = xml.HTMLAutoClose
+	decoder.Entity = xml.HTMLEntity
+
+	var pages []PdfTextLayout
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("unable to read pdftotext output: %w", err)
+		}
+
+		element, ok := token.(xml.StartElement)
+		if !ok {
+			continue
+		}
+
+		switch element.Name.Local {
+		case "page":
+			pages = append(pages, PdfTextLayout{
+				Width:  xmlFloatAttr(element, "width"),
+				Height: xmlFloatAttr(element, "height"),
+			})
+		case "word":
+			if len(pages) == 0 {
+				continue
+			}
+
+			var text string
+			if err := decoder.DecodeElement(&text, &element); err != nil {
+				return nil, fmt.Errorf("unable to read pdftotext output: %w", err)
+			}
+
+			xMin, yMin := xmlFloatAttr(element, "xMin"), xmlFloatAttr(element, "yMin")
+			page := &pages[len(pages)-1]
+			page.Words = append(page.Words, PdfTextWord{
+				Text:   text,
+				X:      xMin,
+				Y:      yMin,
+				Width:  xmlFloatAttr(element, "xMax") - xMin,
+				Height: xmlFloatAttr(element, "yMax") - yMin,
+			})
+		}
+	}
+
+	return pages, nil
+}
+
+func xmlFloatAttr(element xml.StartElement, name string) float64 {
+	for _, attr := range element.Attr {
+		if attr.Name.Local == name {
+			value, _ := strconv.ParseFloat(attr.Value, 64)
+			return value
+		}
+	}
+
+	return 0
+}
+
+// runCommand runs an external tool, including its stderr output in the returned error
+func runCommand(path string, args ...string) error {
+	_, err := runCommandOutput(path, args...)
+
+	return err
+}
+
+// runCommandOutput runs an external tool and returns what it wrote to stdout
+func runCommandOutput(path string, args ...string) ([]byte, error) {
+	var stdout bytes.Buffer
+	var stderr bytes.Buffer
+	cmd := exec.Command(path, args...)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
 	if err := cmd.Run(); err != nil {
-		return nil, errors.New("unable to combine pdfs")
+		if message := strings.TrimSpace(stderr.String()); message != "" {
+			return nil, fmt.Errorf("%s: %w: %s", filepath.Base(path), err, message)
+		}
+
+		return nil, fmt.Errorf("%s: %w", filepath.Base(path), err)
 	}
 
-	return combinedFile, nil
+	return stdout.Bytes(), nil
 }
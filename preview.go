@@ -0,0 +1,310 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+
+	"github.com/gin-contrib/location"
+	"github.com/gin-gonic/gin"
+	"github.com/pdfcpu/pdfcpu/pkg/api"
+)
+
+const (
+	PreviewFormatJpeg = "jpeg"
+	PreviewFormatPng  = "png"
+	PreviewFormatWebp = "webp"
+)
+
+const defaultPreviewSize = "default"
+
+var previewSizeNamePattern = regexp.MustCompile(`^[A-Za-z0-9_]+$`)
+
+type PdfPreviewOptions struct {
+	Format  string           `json:"format" form:"previewFormat"`
+	Quality int              `json:"quality" form:"previewQuality"`
+	Pages   string           `json:"pages" form:"previewPages"`
+	Sizes   []PdfPreviewSize `json:"sizes" form:"-"`
+	// Lazy previews are rendered by /documents when they are first requested
+	Lazy bool `json:"lazy" form:"previewLazy"`
+}
+
+// PdfPreviewSize is a named image size, set either the resolution or the width. Without either the longest side of
+// the image is 1024 pixels.
+type PdfPreviewSize struct {
+	Name  string `json:"name"`
+	Dpi   int    `json:"dpi"`
+	Width int    `json:"width"`
+}
+
+// scale returns the pixels per point of the images of a page of width by height points, as pdftocairo renders them
+func (size PdfPreviewSize) scale(width float64, height float64) float64 {
+	switch {
+	case size.Dpi > 0:
+		return float64(size.Dpi) / 72
+	case size.Width > 0:
+		if width > 0 {
+			return float64(size.Width) / width
+		}
+	default:
+		if longestSide := max(width, height); longestSide > 0 {
+			return previewScaleTo / longestSide
+		}
+	}
+
+	return 0
+}
+
+// PdfPreviewPage holds the image of a page for every requested size
+type PdfPreviewPage struct {
+	Page   int               `json:"page"`
+	Images map[string]string `json:"images"`
+}
+
+type PdfSourcePreviewRequest struct {
+	PdfSource
+	Preview   *PdfPreviewOptions `json:"preview" form:"preview"`
+	Text      bool               `json:"text" form:"text"`
+	TextWords bool               `json:"textWords" form:"textWords"`
+}
+
+// RasterOptions controls how a PdfRasterizer renders pages, it renders every page when FirstPage and LastPage are 0
+type RasterOptions struct {
+	Format    string
+	Quality   int
+	Dpi       int
+	Width     int
+	FirstPage int
+	LastPage  int
+}
+
+// validatePreviewOptions checks the options and fills in the defaults
+func validatePreviewOptions(previewOptions *PdfPreviewOptions, options *ServerOptions) error {
+	switch previewOptions.Format {
+	case "":
+		previewOptions.Format = PreviewFormatJpeg
+	case PreviewFormatJpeg, PreviewFormatPng:
+	case PreviewFormatWebp:
+		if options.WebpEncoder == nil {
+			return errors.New("webp previews are unavailable, install libwebp-tools")
+		}
+	default:
+		return fmt.Errorf("invalid preview format %q, expected jpeg, png or webp", previewOptions.Format)
+	}
+
+	if previewOptions.Quality < 0 || previewOptions.Quality > 100 {
+		return errors.New("preview quality must be between 1 and 100")
+	}
+
+	if len(previewOptions.Sizes) == 0 {
+		previewOptions.Sizes = []PdfPreviewSize{{Name: defaultPreviewSize}}
+	}
+
+	var names []string
+	for _, size := range previewOptions.Sizes {
+		if !previewSizeNamePattern.MatchString(size.Name) {
+			return fmt.Errorf("invalid preview size name %q, use letters, digits and underscores", size.Name)
+		}
+
+		if slices.Contains(names, size.Name) {
+			return fmt.Errorf("preview size %q is requested more than once", size.Name)
+		}
+		names = append(names, size.Name)
+
+		if size.Dpi < 0 || size.Width < 0 {
+			return fmt.Errorf("preview size %q must be positive", size.Name)
+		}
+
+		if size.Dpi > maxPageImageDpi || size.Width > maxPageImageWidth {
+			return fmt.Errorf("preview size %q is larger than %d dpi or %d pixels wide", size.Name, maxPageImageDpi, maxPageImageWidth)
+		}
+
+		if size.Dpi > 0 && size.Width > 0 {
+			return fmt.Errorf("preview size %q sets both dpi and width", size.Name)
+		}
+	}
+
+	return nil
+}
+
+// getPreviewOptions returns the validated preview options of a request, form submissions provide the sizes as a json
+// string
+func getPreviewOptions(c *gin.Context, previewOptions *PdfPreviewOptions, options *ServerOptions) (*PdfPreviewOptions, error) {
+	if previewOptions == nil {
+		previewOptions = &PdfPreviewOptions{}
+	}
+
+	if sizes := c.PostForm("previewSizes"); sizes != "" && previewOptions.Sizes == nil {
+		if err := json.Unmarshal([]byte(sizes), &previewOptions.Sizes); err != nil {
+			return nil, fmt.Errorf("invalid previewSizes: %w", err)
+		}
+	}
+
+	if err := validatePreviewOptions(previewOptions, options); err != nil {
+		return nil, err
+	}
+
+	return previewOptions, nil
+}
+
+// buildPreviewResponse renders, or links to when they are lazy, the page images of pdfFile and extracts its text
+func buildPreviewResponse(c *gin.Context, pdfFile string, pdfInfo *PdfInfo, previewOptions *PdfPreviewOptions, text bool, textWords bool, options *ServerOptions) (*PdfPreviewResponse, error) {
+	var previews []PdfPreviewPage
+	var err error
+	if previewOptions.Lazy {
+		previews, err = lazyPreviews(pdfFile, pdfInfo.Pages, previewOptions)
+	} else {
+		previews, err = createPreviews(pdfFile, pdfInfo.Pages, previewOptions, options)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	serverUrl := location.Get(c)
+	url := serverUrl.Scheme + "://" + serverUrl.Host
+
+	// images keeps listing the first size on its own
+	var images []string
+	for index := range previews {
+		for name, image := range previews[index].Images {
+			if previewOptions.Lazy {
+				previews[index].Images[name] = url + image
+			} else {
+				previews[index].Images[name] = url + "/preview/" + filepath.Base(image)
+			}
+		}
+		images = append(images, previews[index].Images[previewOptions.Sizes[0].Name])
+	}
+
+	response := &PdfPreviewResponse{Pages: pdfInfo.Pages, Images: images, Previews: previews, Info: pdfInfo}
+	if text {
+		// The text lines up with the images listed in images
+		response.Text, err = extractPdfText(pdfFile, textWords, previewOptions.Sizes[0], options)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return response, nil
+}
+
+// createPreviews renders the selected pages of pdfFile at every requested size and returns the image files by page
+func createPreviews(pdfFile string, pageCount int, previewOptions *PdfPreviewOptions, options *ServerOptions) ([]PdfPreviewPage, error) {
+	if options.Rasterizer == nil {
+		return nil, errors.New("unable to produce pdf image previews: no rasterizer is available, install poppler-utils")
+	}
+
+	pageNumbers, err := selectPreviewPages(previewOptions.Pages, pageCount)
+	if err != nil {
+		return nil, err
+	}
+
+	pages := make([]PdfPreviewPage, len(pageNumbers))
+	for index, pageNumber := range pageNumbers {
+		pages[index] = PdfPreviewPage{Page: pageNumber, Images: make(map[string]string)}
+	}
+
+	// WebP images are converted from png
+	rasterFormat := previewOptions.Format
+	if rasterFormat == PreviewFormatWebp {
+		rasterFormat = PreviewFormatPng
+	}
+
+	baseName := fileNameWithoutExtension(filepath.Base(pdfFile))
+	for _, size := range previewOptions.Sizes {
+		outputPrefix := filepath.Join(*options.DirectoryMap[DirectoryKeyPreview], baseName+"-"+size.Name)
+
+		images := make(map[int]string)
+		for _, pageRange := range pageRanges(pageNumbers) {
+			rangeImages, err := options.Rasterizer.Rasterize(pdfFile, outputPrefix, RasterOptions{
+				Format:    rasterFormat,
+				Quality:   previewOptions.Quality,
+				Dpi:       size.Dpi,
+				Width:     size.Width,
+				FirstPage: pageRange[0],
+				LastPage:  pageRange[1],
+			})
+			if err != nil {
+				return nil, fmt.Errorf("unable to produce pdf image previews: %w", err)
+			}
+
+			for pageNumber, image := range rangeImages {
+				images[pageNumber] = image
+			}
+		}
+
+		for index := range pages {
+			image, found := images[pages[index].Page]
+			if !found {
+				return nil, fmt.Errorf("unable to produce pdf image previews: page %d was not rendered", pages[index].Page)
+			}
+
+			if previewOptions.Format == PreviewFormatWebp {
+				image, err = convertToWebp(image, previewOptions.Quality, options)
+				if err != nil {
+					return nil, fmt.Errorf("unable to produce pdf image previews: %w", err)
+				}
+			}
+
+			pages[index].Images[size.Name] = image
+		}
+	}
+
+	return pages, nil
+}
+
+// convertToWebp replaces image with a WebP image and returns its name
+func convertToWebp(image string, quality int, options *ServerOptions) (string, error) {
+	webpImage := fileNameWithoutExtension(image) + ".webp"
+	if err := options.WebpEncoder.Encode(image, webpImage, quality); err != nil {
+		return "", err
+	}
+	os.Remove(image)
+
+	return webpImage, nil
+}
+
+// selectPreviewPages returns the sorted page numbers of a page selection like "1-3,5", every page when it is empty
+func selectPreviewPages(selection string, pageCount int) ([]int, error) {
+	selectedPages, err := api.ParsePageSelection(selection)
+	if err != nil {
+		return nil, fmt.Errorf("invalid preview pages %q: %w", selection, err)
+	}
+
+	pageSet, err := api.PagesForPageSelection(pageCount, selectedPages, true, false)
+	if err != nil {
+		return nil, fmt.Errorf("invalid preview pages %q: %w", selection, err)
+	}
+
+	var pageNumbers []int
+	for pageNumber, selected := range pageSet {
+		if selected && pageNumber <= pageCount {
+			pageNumbers = append(pageNumbers, pageNumber)
+		}
+	}
+	slices.Sort(pageNumbers)
+
+	if len(pageNumbers) == 0 {
+		return nil, fmt.Errorf("preview pages %q selects no pages", selection)
+	}
+
+	return pageNumbers, nil
+}
+
+// pageRanges groups sorted page numbers into [first, last] runs of consecutive pages
+func pageRanges(pageNumbers []int) [][2]int {
+	var ranges [][2]int
+	for _, pageNumber := range pageNumbers {
+		if len(ranges) > 0 && ranges[len(ranges)-1][1] == pageNumber-1 {
+			ranges[len(ranges)-1][1] = pageNumber
+			continue
+		}
+		ranges = append(ranges, [2]int{pageNumber, pageNumber})
+	}
+
+	return ranges
+}
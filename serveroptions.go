@@ -2,6 +2,7 @@ package main
 
 import (
 	"fmt"
+	"html/template"
 	"os"
 	"strconv"
 
@@ -13,6 +14,7 @@ const (
 	DirectoryKeyPng     string = "png"
 	DirectoryKeyPdf     string = "pdfs"
 	DirectoryKeyPreview string = "preview"
+	DirectoryKeyArchive string = "archives"
 )
 
 type ServerOptions struct {
@@ -27,9 +29,22 @@ type ServerOptions struct {
 	RootDirectory       *string
 	DirectoryMap        map[string]*string
 	HeaderStyleTemplate string
+	TocTemplate         *template.Template
+	MarkdownStylesheet  string
 	ChromeUri           string
 	Debug               bool
 	DebugSources        bool
+	SigningIdentities   map[string]*SigningIdentity
+	SigningPassword     string
+	SigningTSAUrl       string
+	PdfEngine           PdfEngine
+	Rasterizer          PdfRasterizer
+	TextExtractor       PdfTextExtractor
+	WebpEncoder         *CwebpEncoder
+	AnimationEncoder    *Img2webpEncoder
+	TabPool             *TabPool
+	FetchUrls           bool
+	MaxSourceSize       int64
 }
 
 func New(src *ServerOptions) *ServerOptions {
@@ -41,6 +56,9 @@ func New(src *ServerOptions) *ServerOptions {
 	options.Debug = false
 	options.DebugSources = false
 	options.ChromeUri = "127.0.0.1:1337"
+	options.TabPool = NewTabPool(4)
+	options.FetchUrls = false
+	options.MaxSourceSize = 100 << 20
 
 	rootDirectory := os.Getenv("REMOTE_PDF_ROOT_DIRECTORY")
 	if rootDirectory != "" {
@@ -74,6 +92,40 @@ func New(src *ServerOptions) *ServerOptions {
 
 	options.HeaderStyleTemplate = string(headerTemplateBytes) // convert content to a 'string'
 
+	tocTemplatePath := os.Getenv("REMOTE_PDF_TOC_TEMPLATE")
+	if tocTemplatePath != "" {
+		if !pathExists(tocTemplatePath) {
+			panic("Unable to locate table of contents template path\n")
+		}
+	} else {
+		tocTemplatePath = *options.RootDirectory + "/css/default-toc.html.tmpl"
+	}
+
+	tocTemplate, err := loadTocTemplate(tocTemplatePath)
+	if err != nil {
+		errorString := fmt.Sprintf("Unable to load table of contents template: %s\n", err.Error())
+		panic(errorString)
+	}
+
+	options.TocTemplate = tocTemplate
+
+	markdownStylesheetPath := os.Getenv("REMOTE_PDF_MARKDOWN_STYLESHEET")
+	if markdownStylesheetPath != "" {
+		if !pathExists(markdownStylesheetPath) {
+			panic("Unable to locate markdown stylesheet path\n")
+		}
+	} else {
+		markdownStylesheetPath = *options.RootDirectory + "/css/default-markdown.css"
+	}
+
+	markdownStylesheetBytes, err := os.ReadFile(markdownStylesheetPath)
+	if err != nil {
+		errorString := fmt.Sprintf("%s\n", err.Error())
+		panic(errorString)
+	}
+
+	options.MarkdownStylesheet = string(markdownStylesheetBytes)
+
 	debug := os.Getenv("REMOTE_PDF_DEBUG")
 	if debug != "" {
 		boolVal, err := strconv.ParseBool(debug)
@@ -134,6 +186,46 @@ func New(src *ServerOptions) *ServerOptions {
 		options.ChromeUri = address
 	}
 
+	tabs := os.Getenv("REMOTE_PDF_CHROME_TABS")
+	if tabs != "" {
+		intTabs, err := strconv.Atoi(tabs)
+		if err != nil || intTabs < 1 {
+			panic("Unable to parse env REMOTE_PDF_CHROME_TABS\n")
+		}
+
+		if options.Debug {
+			fmt.Printf("Setting chrome tabs to %d\n", intTabs)
+		}
+		options.TabPool = NewTabPool(intTabs)
+	}
+
+	fetchUrls := os.Getenv("REMOTE_PDF_FETCH_URLS")
+	if fetchUrls != "" {
+		boolVal, err := strconv.ParseBool(fetchUrls)
+		if err != nil {
+			panic("Unable to parse env REMOTE_PDF_FETCH_URLS\n")
+		}
+
+		if options.Debug {
+			fmt.Printf("Setting FetchUrls to %t\n", boolVal)
+		}
+
+		options.FetchUrls = boolVal
+	}
+
+	maxSourceSize := os.Getenv("REMOTE_PDF_MAX_SOURCE_SIZE")
+	if maxSourceSize != "" {
+		intSize, err := strconv.ParseInt(maxSourceSize, 10, 64)
+		if err != nil || intSize < 1 {
+			panic("Unable to parse env REMOTE_PDF_MAX_SOURCE_SIZE\n")
+		}
+
+		if options.Debug {
+			fmt.Printf("Setting MaxSourceSize to %d\n", intSize)
+		}
+		options.MaxSourceSize = intSize
+	}
+
 	useTls := os.Getenv("REMOTE_PDF_TLS_ENABLE")
 	if useTls != "" {
 		boolVal, err := strconv.ParseBool(useTls)
@@ -159,6 +251,17 @@ func New(src *ServerOptions) *ServerOptions {
 
 	options.CertDirectory = &certDir
 
+	options.SigningPassword = os.Getenv("REMOTE_PDF_SIGNING_PASSWORD")
+
+	tsaUrl := os.Getenv("REMOTE_PDF_SIGNING_TSA_URL")
+	if tsaUrl != "" {
+		if options.Debug {
+			fmt.Printf("Setting SigningTSAUrl to %s\n", tsaUrl)
+		}
+
+		options.SigningTSAUrl = tsaUrl
+	}
+
 	if options.UseTLS {
 		certPath := os.Getenv("REMOTE_PDF_TLS_CERT_PATH")
 		if certPath == "" || (certPath != "" && !pathExists(certPath)) {
@@ -173,6 +276,44 @@ func New(src *ServerOptions) *ServerOptions {
 		options.KeyPath = &keyPath
 	}
 
+	options.PdfEngine = NewPdfcpuEngine()
+
+	popplerPath := os.Getenv("REMOTE_PDF_POPPLER_PATH")
+	if popplerPath == "" {
+		popplerPath = "/usr/bin"
+	}
+
+	// Poppler is optional, without it previews are unavailable
+	if rasterizer := NewPopplerRasterizer(popplerPath); rasterizer != nil {
+		options.Rasterizer = rasterizer
+	} else {
+		fmt.Printf("Unable to locate pdftocairo in %s, previews are disabled\n", popplerPath)
+	}
+
+	if extractor := NewPopplerTextExtractor(popplerPath); extractor != nil {
+		options.TextExtractor = extractor
+	} else {
+		fmt.Printf("Unable to locate pdftotext in %s, text extraction is disabled\n", popplerPath)
+	}
+
+	cwebpPath := os.Getenv("REMOTE_PDF_CWEBP_PATH")
+	if cwebpPath == "" {
+		cwebpPath = "/usr/bin"
+	}
+
+	// cwebp is optional, without it previews can not be WebP images
+	if encoder := NewCwebpEncoder(cwebpPath); encoder != nil {
+		options.WebpEncoder = encoder
+	} else {
+		fmt.Printf("Unable to locate cwebp in %s, webp previews are disabled\n", cwebpPath)
+	}
+
+	if encoder := NewImg2webpEncoder(cwebpPath); encoder != nil {
+		options.AnimationEncoder = encoder
+	} else {
+		fmt.Printf("Unable to locate img2webp in %s, webp animations are disabled\n", cwebpPath)
+	}
+
 	logPath := os.Getenv("REMOTE_PDF_LOG_PATH")
 	if logPath != "" {
 		options.LogPath = logPath
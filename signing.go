@@ -0,0 +1,714 @@
+package main
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/x509"
+	"encoding/asn1"
+	"encoding/hex"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/digitorus/pkcs7"
+	"github.com/digitorus/timestamp"
+	"github.com/pdfcpu/pdfcpu/pkg/api"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/model"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/types"
+	"software.sslmate.com/src/go-pkcs12"
+)
+
+// Size reserved for the hex encoded CMS signature, large enough for a chain and a timestamp token
+const signatureContentsLength = 32768
+
+// placeholder for [0 offset1 length1 offset2 length2], patched in place once the offsets are known
+const signatureByteRangePlaceholder = "[0 0000000000 0000000000 0000000000]"
+
+// Validation results that mean the signature itself is broken, rather than the certificate not being trusted
+var signatureIntegrityFailures = []model.SignatureReason{
+	model.SignatureReasonDocModified,
+	model.SignatureReasonSignatureForged,
+	model.SignatureReasonMalformed,
+	model.SignatureReasonUnsupported,
+	model.SignatureReasonInternal,
+}
+
+var oidAttributeTimeStampToken = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 16, 2, 14}
+
+type SigningIdentity struct {
+	Name        string
+	Certificate *x509.Certificate
+	Chain       []*x509.Certificate
+	PrivateKey  crypto.Signer
+}
+
+type PdfSignature struct {
+	Identity    string    `json:"identity" form:"signatureIdentity"`
+	Visible     bool      `json:"visible" form:"signatureVisible"`
+	Page        int       `json:"page" form:"signaturePage"`
+	Rect        []float64 `json:"rect" form:"signatureRect"`
+	Reason      string    `json:"reason" form:"signatureReason"`
+	Location    string    `json:"location" form:"signatureLocation"`
+	ContactInfo string    `json:"contactInfo" form:"signatureContactInfo"`
+	Timestamp   bool      `json:"timestamp" form:"signatureTimestamp"`
+}
+
+type PdfSignatureDetails struct {
+	Identity     string     `json:"identity"`
+	Valid        bool       `json:"valid"`
+	Trusted      bool       `json:"trusted"`
+	Status       string     `json:"status"`
+	Subject      string     `json:"subject"`
+	Issuer       string     `json:"issuer"`
+	SerialNumber string     `json:"serialNumber"`
+	SelfSigned   bool       `json:"selfSigned"`
+	Reason       string     `json:"reason"`
+	Location     string     `json:"location"`
+	ContactInfo  string     `json:"contactInfo"`
+	SigningTime  time.Time  `json:"signingTime"`
+	Timestamp    *time.Time `json:"timestamp,omitempty"`
+	Visible      bool       `json:"visible"`
+	Page         int        `json:"page"`
+	Problems     []string   `json:"problems,omitempty"`
+}
+
+// loadSigningIdentities reads every PKCS#12 (.p12/.pfx) and PEM (.pem/.crt/.cer) certificate in the cert directory.
+// PEM certificates need their key either in the same file or in a matching .key file. Certificates placed in the
+// trusted subdirectory are used when verifying signatures.
+func loadSigningIdentities(options *ServerOptions) {
+	options.SigningIdentities = make(map[string]*SigningIdentity)
+	if options.CertDirectory == nil {
+		return
+	}
+
+	model.TrustedCertDir = filepath.Join(*options.CertDirectory, "trusted")
+
+	entries, err := os.ReadDir(*options.CertDirectory)
+	if err != nil {
+		log.Printf("Unable to read cert directory: %s", err.Error())
+		return
+	}
+
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+
+		var identity *SigningIdentity
+		path := filepath.Join(*options.CertDirectory, entry.Name())
+		switch strings.ToLower(filepath.Ext(entry.Name())) {
+		case ".p12", ".pfx":
+			identity, err = loadPkcs12Identity(path, options.SigningPassword)
+		case ".pem", ".crt", ".cer":
+			identity, err = loadPemIdentity(path)
+		default:
+			continue
+		}
+
+		if err != nil {
+			log.Printf("Skipping signing identity %s: %s", entry.Name(), err.Error())
+			continue
+		}
+
+		identity.Name = fileNameWithoutExtension(entry.Name())
+		if _, exists := options.SigningIdentities[identity.Name]; exists {
+			log.Printf("Skipping signing identity %s: an identity named %s is already loaded", entry.Name(), identity.Name)
+			continue
+		}
+
+		if options.Debug {
+			fmt.Printf("Loaded signing identity %s (%s)\n", identity.Name, identity.Certificate.Subject.String())
+		}
+
+		options.SigningIdentities[identity.Name] = identity
+	}
+}
+
+func loadPkcs12Identity(path string, password string) (*SigningIdentity, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	key, certificate, chain, err := pkcs12.DecodeChain(data, password)
+	if err != nil {
+		return nil, fmt.Errorf("unable to decode pkcs12 file: %w", err)
+	}
+
+	signer, ok := key.(crypto.Signer)
+	if !ok {
+		return nil, errors.New("unsupported private key type")
+	}
+
+	return &SigningIdentity{Certificate: certificate, Chain: chain, PrivateKey: signer}, nil
+}
+
+func loadPemIdentity(path string) (*SigningIdentity, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	keyPath := fileNameWithoutExtension(path) + ".key"
+	if pathExists(keyPath) {
+		keyData, err := os.ReadFile(keyPath)
+		if err != nil {
+			return nil, err
+		}
+		data = append(append(data, '\n'), keyData...)
+	}
+
+	identity := &SigningIdentity{}
+	for {
+		var block *pem.Block
+		block, data = pem.Decode(data)
+		if block == nil {
+			break
+		}
+
+		switch {
+		case block.Type == "CERTIFICATE":
+			certificate, err := x509.ParseCertificate(block.Bytes)
+			if err != nil {
+				return nil, fmt.Errorf("unable to parse certificate: %w", err)
+			}
+
+			if identity.Certificate == nil {
+				identity.Certificate = certificate
+			} else {
+				identity.Chain = append(identity.Chain, certificate)
+			}
+		case strings.HasSuffix(block.Type, "PRIVATE KEY"):
+			signer, err := parsePrivateKey(block)
+			if err != nil {
+				return nil, err
+			}
+			identity.PrivateKey = signer
+		}
+	}
+
+	if identity.Certificate == nil {
+		return nil, errors.New("no certificate found")
+	}
+
+	if identity.PrivateKey == nil {
+		return nil, errors.New("no private key found")
+	}
+
+	return identity, nil
+}
+
+func parsePrivateKey(block *pem.Block) (crypto.Signer, error) {
+	var key any
+	var err error
+	switch block.Type {
+	case "RSA PRIVATE KEY":
+		key, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "EC PRIVATE KEY":
+		key, err = x509.ParseECPrivateKey(block.Bytes)
+	default:
+		key, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse private key: %w", err)
+	}
+
+	signer, ok := key.(crypto.Signer)
+	if !ok {
+		return nil, errors.New("unsupported private key type")
+	}
+
+	return signer, nil
+}
+
+func getSigningIdentity(signature *PdfSignature, serverOptions *ServerOptions) (*SigningIdentity, error) {
+	if signature.Timestamp && serverOptions.SigningTSAUrl == "" {
+		return nil, errors.New("a timestamp was requested but no timestamp authority is configured")
+	}
+
+	if signature.Visible && len(signature.Rect) != 0 && len(signature.Rect) != 4 {
+		return nil, errors.New("signature rect must be [x, y, width, height]")
+	}
+
+	if signature.Identity == "" {
+		if len(serverOptions.SigningIdentities) != 1 {
+			return nil, errors.New("a signing identity is required when zero or several are configured")
+		}
+
+		for _, identity := range serverOptions.SigningIdentities {
+			return identity, nil
+		}
+	}
+
+	identity, ok := serverOptions.SigningIdentities[signature.Identity]
+	if !ok {
+		return nil, fmt.Errorf("unknown signing identity %s", signature.Identity)
+	}
+
+	return identity, nil
+}
+
+// signPdf adds a signature to pdfFile as an incremental update, nothing may modify the file afterwards
+func signPdf(pdfFile string, signature *PdfSignature, serverOptions *ServerOptions) (*PdfSignatureDetails, error) {
+	identity, err := getSigningIdentity(signature, serverOptions)
+	if err != nil {
+		return nil, err
+	}
+
+	original, err := os.ReadFile(pdfFile)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read pdf to sign: %w", err)
+	}
+
+	signingTime := time.Now()
+	output, contentsOffset, byteRangeOffset, err := appendSignatureUpdate(original, signature, identity, signingTime)
+	if err != nil {
+		return nil, fmt.Errorf("unable to prepare pdf signature: %w", err)
+	}
+
+	contentsEnd := contentsOffset + signatureContentsLength + 2
+	byteRange := fmt.Sprintf("[0 %d %d %d", contentsOffset, contentsEnd, len(output)-contentsEnd)
+	byteRange += strings.Repeat(" ", len(signatureByteRangePlaceholder)-len(byteRange)-1) + "]"
+	copy(output[byteRangeOffset:], byteRange)
+
+	signedContent := slices.Concat(output[:contentsOffset], output[contentsEnd:])
+	cms, err := createSignature(signedContent, identity, signature.Timestamp, serverOptions.SigningTSAUrl)
+	if err != nil {
+		return nil, err
+	}
+
+	encodedCms := hex.EncodeToString(cms)
+	if len(encodedCms) > signatureContentsLength {
+		return nil, fmt.Errorf("signature is %d bytes, larger than the %d bytes reserved", len(encodedCms), signatureContentsLength)
+	}
+	copy(output[contentsOffset+1:], encodedCms)
+
+	if err := os.WriteFile(pdfFile, output, 0640); err != nil {
+		return nil, fmt.Errorf("unable to write signed pdf: %w", err)
+	}
+
+	return verifyPdfSignature(pdfFile, identity.Name)
+}
+
+func createSignature(content []byte, identity *SigningIdentity, withTimestamp bool, tsaUrl string) ([]byte, error) {
+	signedData, err := pkcs7.NewSignedData(content)
+	if err != nil {
+		return nil, fmt.Errorf("unable to create signature: %w", err)
+	}
+
+	signedData.SetDigestAlgorithm(pkcs7.OIDDigestAlgorithmSHA256)
+	if err := signedData.AddSignerChain(identity.Certificate, identity.PrivateKey, identity.Chain, pkcs7.SignerInfoConfig{}); err != nil {
+		return nil, fmt.Errorf("unable to create signature: %w", err)
+	}
+
+	signedData.Detach()
+
+	if withTimestamp {
+		signerInfo := &signedData.GetSignedData().SignerInfos[0]
+		token, err := requestTimestampToken(signerInfo.EncryptedDigest, tsaUrl)
+		if err != nil {
+			return nil, err
+		}
+
+		err = signerInfo.SetUnauthenticatedAttributes([]pkcs7.Attribute{{Type: oidAttributeTimeStampToken, Value: asn1.RawValue{FullBytes: token}}})
+		if err != nil {
+			return nil, fmt.Errorf("unable to add timestamp to signature: %w", err)
+		}
+	}
+
+	cms, err := signedData.Finish()
+	if err != nil {
+		return nil, fmt.Errorf("unable to create signature: %w", err)
+	}
+
+	return cms, nil
+}
+
+// requestTimestampToken asks the RFC 3161 timestamp authority to timestamp the signature value
+func requestTimestampToken(signatureValue []byte, tsaUrl string) ([]byte, error) {
+	request, err := timestamp.CreateRequest(bytes.NewReader(signatureValue), &timestamp.RequestOptions{Hash: crypto.SHA256, Certificates: true})
+	if err != nil {
+		return nil, fmt.Errorf("unable to create timestamp request: %w", err)
+	}
+
+	client := http.Client{Timeout: 30 * time.Second}
+	response, err := client.Post(tsaUrl, "application/timestamp-query", bytes.NewReader(request))
+	if err != nil {
+		return nil, fmt.Errorf("unable to reach timestamp authority: %w", err)
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("timestamp authority returned %s", response.Status)
+	}
+
+	body, err := io.ReadAll(response.Body)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read timestamp response: %w", err)
+	}
+
+	token, err := timestamp.ParseResponse(body)
+	if err != nil {
+		return nil, fmt.Errorf("invalid timestamp response: %w", err)
+	}
+
+	return token.RawToken, nil
+}
+
+func verifyPdfSignature(pdfFile string, identityName string) (*PdfSignatureDetails, error) {
+	conf := model.NewDefaultConfiguration()
+	conf.Offline = true
+
+	results, err := api.ValidateSignatures(pdfFile, false, conf)
+	if err != nil {
+		return nil, fmt.Errorf("unable to verify pdf signature: %w", err)
+	}
+
+	if len(results) == 0 {
+		return nil, errors.New("unable to verify pdf signature: no signature found")
+	}
+
+	result := results[0]
+	details := &PdfSignatureDetails{
+		Identity:    identityName,
+		Valid:       !slices.Contains(signatureIntegrityFailures, result.Reason),
+		Trusted:     result.Status == model.SignatureStatusValid,
+		Status:      result.Status.String(),
+		Reason:      result.Details.Reason,
+		Location:    result.Details.Location,
+		ContactInfo: result.Details.ContactInfo,
+		SigningTime: result.Details.SigningTime,
+		Visible:     result.Visible,
+		Page:        result.PageNr,
+		Problems:    result.Problems,
+	}
+
+	if len(result.Details.Signers) > 0 {
+		signer := result.Details.Signers[0]
+		if signer.Certificate != nil {
+			details.Subject = signer.Certificate.Subject
+			details.Issuer = signer.Certificate.Issuer
+			details.SerialNumber = signer.Certificate.SerialNumber
+			details.SelfSigned = signer.Certificate.SelfSigned
+		}
+
+		if signer.HasTimestamp {
+			details.Timestamp = &signer.Timestamp
+		}
+
+		details.Problems = append(details.Problems, signer.Problems...)
+	}
+
+	if !details.Valid {
+		return details, fmt.Errorf("pdf signature did not verify: %s", result.Reason)
+	}
+
+	return details, nil
+}
+
+// appendSignatureUpdate appends an incremental update with the signature field, widget and an empty signature
+// dictionary. It returns the updated file along with the offsets of the /Contents and /ByteRange placeholders.
+func appendSignatureUpdate(original []byte, signature *PdfSignature, identity *SigningIdentity, signingTime time.Time) ([]byte, int, int, error) {
+	ctx, err := api.ReadContext(bytes.NewReader(original), model.NewDefaultConfiguration())
+	if err != nil {
+		return nil, 0, 0, err
+	}
+
+	if ctx.Encrypt != nil {
+		return nil, 0, 0, errors.New("signing encrypted pdfs is not supported")
+	}
+
+	if err := ctx.EnsurePageCount(); err != nil {
+		return nil, 0, 0, err
+	}
+
+	previousXref, err := findStartXref(original)
+	if err != nil {
+		return nil, 0, 0, err
+	}
+
+	pageNumber := signature.Page
+	if pageNumber == 0 {
+		pageNumber = ctx.PageCount
+	}
+
+	if pageNumber < 1 || pageNumber > ctx.PageCount {
+		return nil, 0, 0, fmt.Errorf("signature page %d is out of range, the document has %d pages", pageNumber, ctx.PageCount)
+	}
+
+	pageDict, pageRef, _, err := ctx.PageDict(pageNumber, false)
+	if err != nil || pageRef == nil {
+		return nil, 0, 0, fmt.Errorf("unable to locate page %d", pageNumber)
+	}
+
+	nextObject := *ctx.Size
+	sigRef := types.NewIndirectRef(nextObject, 0)
+	widgetRef := types.NewIndirectRef(nextObject+1, 0)
+	nextObject += 2
+
+	objects := make(map[int]string)
+
+	// the catalog or the acroform dictionary it references gets the new field
+	catalog := ctx.RootDict.Clone().(types.Dict)
+	acroForm := types.Dict{}
+	acroFormRef, isRef := catalog["AcroForm"].(types.IndirectRef)
+	if existing, err := ctx.DereferenceDict(catalog["AcroForm"]); err == nil && existing != nil {
+		acroForm = existing.Clone().(types.Dict)
+	}
+
+	fields, err := ctx.DereferenceArray(acroForm["Fields"])
+	if err != nil {
+		return nil, 0, 0, err
+	}
+	fields = append(slices.Clone(fields), *widgetRef)
+	acroForm["Fields"] = fields
+	acroForm["SigFlags"] = types.Integer(3)
+
+	if isRef {
+		objects[acroFormRef.ObjectNumber.Value()] = acroForm.PDFString()
+	} else {
+		catalog["AcroForm"] = acroForm
+		objects[ctx.Root.ObjectNumber.Value()] = catalog.PDFString()
+	}
+
+	annotations, err := ctx.DereferenceArray(pageDict["Annots"])
+	if err != nil {
+		return nil, 0, 0, err
+	}
+	page := pageDict.Clone().(types.Dict)
+	page["Annots"] = append(slices.Clone(annotations), *widgetRef)
+	objects[pageRef.ObjectNumber.Value()] = page.PDFString()
+
+	widget := types.Dict{
+		"Type":    types.Name("Annot"),
+		"Subtype": types.Name("Widget"),
+		"FT":      types.Name("Sig"),
+		"T":       types.StringLiteral(fmt.Sprintf("Signature%d", len(fields))),
+		"V":       *sigRef,
+		"P":       *pageRef,
+		"F":       types.Integer(132), // print and locked
+		"Rect":    types.NewIntegerArray(0, 0, 0, 0),
+	}
+
+	if signature.Visible {
+		rect := signature.Rect
+		if len(rect) != 4 {
+			rect = []float64{36, 36, 200, 50}
+		}
+
+		appearanceRef := types.NewIndirectRef(nextObject, 0)
+		nextObject++
+
+		widget["Rect"] = types.NewNumberArray(rect[0], rect[1], rect[0]+rect[2], rect[1]+rect[3])
+		widget["AP"] = types.Dict{"N": *appearanceRef}
+		objects[appearanceRef.ObjectNumber.Value()] = signatureAppearance(rect[2], rect[3], signature, identity, signingTime)
+	}
+	objects[widgetRef.ObjectNumber.Value()] = widget.PDFString()
+
+	// The signature dictionary is written by hand so the placeholders can be located
+	sigDict := "<< /Type /Sig /Filter /Adobe.PPKLite /SubFilter /adbe.pkcs7.detached"
+	sigDict += " /M " + pdfTextString(formatPdfDate(signingTime))
+	sigDict += " /Name " + pdfTextString(identity.Certificate.Subject.CommonName)
+	if signature.Reason != "" {
+		sigDict += " /Reason " + pdfTextString(signature.Reason)
+	}
+	if signature.Location != "" {
+		sigDict += " /Location " + pdfTextString(signature.Location)
+	}
+	if signature.ContactInfo != "" {
+		sigDict += " /ContactInfo " + pdfTextString(signature.ContactInfo)
+	}
+	sigDict += " /ByteRange " + signatureByteRangePlaceholder
+	sigDict += " /Contents <" + strings.Repeat("0", signatureContentsLength) + "> >>"
+	objects[sigRef.ObjectNumber.Value()] = sigDict
+
+	output := slices.Clone(original)
+	if !bytes.HasSuffix(output, []byte("\n")) {
+		output = append(output, '\n')
+	}
+
+	objectNumbers := slices.Sorted(func(yield func(int) bool) {
+		for objectNumber := range objects {
+			if !yield(objectNumber) {
+				return
+			}
+		}
+	})
+
+	offsets := make(map[int]int)
+	var contentsOffset, byteRangeOffset int
+	for _, objectNumber := range objectNumbers {
+		offsets[objectNumber] = len(output)
+		header := fmt.Sprintf("%d 0 obj\n", objectNumber)
+		if objectNumber == sigRef.ObjectNumber.Value() {
+			contentsOffset = len(output) + len(header) + strings.Index(sigDict, "/Contents <") + len("/Contents ")
+			byteRangeOffset = len(output) + len(header) + strings.Index(sigDict, signatureByteRangePlaceholder)
+		}
+		output = append(output, header+objects[objectNumber]+"\nendobj\n"...)
+	}
+
+	trailer := types.Dict{
+		"Root": *ctx.Root,
+		"Prev": types.Integer(previousXref),
+	}
+	if ctx.Info != nil {
+		trailer["Info"] = *ctx.Info
+	}
+	if len(ctx.ID) > 0 {
+		trailer["ID"] = ctx.ID
+	}
+
+	if bytes.HasPrefix(original[previousXref:], []byte("xref")) {
+		output = appendXrefTable(output, offsets, nextObject, trailer)
+	} else {
+		output = appendXrefStream(output, offsets, nextObject, trailer)
+	}
+
+	return output, contentsOffset, byteRangeOffset, nil
+}
+
+// signatureAppearance builds the form xobject shown for a visible signature
+func signatureAppearance(width float64, height float64, signature *PdfSignature, identity *SigningIdentity, signingTime time.Time) string {
+	lines := []string{"Digitally signed by " + identity.Certificate.Subject.CommonName}
+	if signature.Reason != "" {
+		lines = append(lines, "Reason: "+signature.Reason)
+	}
+	if signature.Location != "" {
+		lines = append(lines, "Location: "+signature.Location)
+	}
+	lines = append(lines, "Date: "+signingTime.Format("2006-01-02 15:04:05 -07:00"))
+
+	fontSize := min(10, (height-4)/(float64(len(lines))*1.2))
+	leading := fontSize * 1.2
+
+	var content strings.Builder
+	fmt.Fprintf(&content, "q 0.5 w 0 0 0 RG 0.25 0.25 %.2f %.2f re S Q\n", width-0.5, height-0.5)
+	fmt.Fprintf(&content, "BT /F1 %.2f Tf %.2f TL 4 %.2f Td\n", fontSize, leading, height-2-fontSize)
+	for i, line := range lines {
+		if i > 0 {
+			content.WriteString("T* ")
+		}
+		escaped, _ := types.Escape(types.UTF8ToCP1252(line))
+		fmt.Fprintf(&content, "(%s) Tj\n", *escaped)
+	}
+	content.WriteString("ET")
+
+	font := "<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica /Encoding /WinAnsiEncoding >>"
+	return fmt.Sprintf("<< /Type /XObject /Subtype /Form /BBox [0 0 %.2f %.2f] /Resources << /Font << /F1 %s >> >> /Length %d >>\nstream\n%s\nendstream",
+		width, height, font, content.Len(), content.String())
+}
+
+func appendXrefTable(output []byte, offsets map[int]int, size int, trailer types.Dict) []byte {
+	xrefOffset := len(output)
+	output = append(output, "xref\n"...)
+	for _, section := range xrefSections(offsets) {
+		output = append(output, fmt.Sprintf("%d %d\n", section[0], len(section))...)
+		for _, objectNumber := range section {
+			output = append(output, fmt.Sprintf("%010d 00000 n\r\n", offsets[objectNumber])...)
+		}
+	}
+
+	trailer["Size"] = types.Integer(size)
+	output = append(output, "trailer\n"+trailer.PDFString()+"\n"...)
+
+	return append(output, fmt.Sprintf("startxref\n%d\n%%%%EOF\n", xrefOffset)...)
+}
+
+func appendXrefStream(output []byte, offsets map[int]int, size int, trailer types.Dict) []byte {
+	// the xref stream is an object itself and lists its own offset
+	xrefObject := size
+	xrefOffset := len(output)
+	offsets[xrefObject] = xrefOffset
+
+	var index types.Array
+	var data []byte
+	for _, section := range xrefSections(offsets) {
+		index = append(index, types.Integer(section[0]), types.Integer(len(section)))
+		for _, objectNumber := range section {
+			offset := offsets[objectNumber]
+			data = append(data, 1, byte(offset>>24), byte(offset>>16), byte(offset>>8), byte(offset), 0, 0)
+		}
+	}
+
+	trailer["Type"] = types.Name("XRef")
+	trailer["Size"] = types.Integer(size + 1)
+	trailer["W"] = types.NewIntegerArray(1, 4, 2)
+	trailer["Index"] = index
+	trailer["Length"] = types.Integer(len(data))
+
+	output = append(output, fmt.Sprintf("%d 0 obj\n%s\nstream\n", xrefObject, trailer.PDFString())...)
+	output = append(output, data...)
+	output = append(output, "\nendstream\nendobj\n"...)
+
+	return append(output, fmt.Sprintf("startxref\n%d\n%%%%EOF\n", xrefOffset)...)
+}
+
+// xrefSections groups object numbers into runs of consecutive numbers
+func xrefSections(offsets map[int]int) [][]int {
+	var objectNumbers []int
+	for objectNumber := range offsets {
+		objectNumbers = append(objectNumbers, objectNumber)
+	}
+	slices.Sort(objectNumbers)
+
+	var sections [][]int
+	for _, objectNumber := range objectNumbers {
+		last := len(sections) - 1
+		if last >= 0 && sections[last][len(sections[last])-1] == objectNumber-1 {
+			sections[last] = append(sections[last], objectNumber)
+			continue
+		}
+		sections = append(sections, []int{objectNumber})
+	}
+
+	return sections
+}
+
+func findStartXref(pdf []byte) (int, error) {
+	matches := regexp.MustCompile(`startxref\s+(\d+)`).FindAllSubmatch(pdf, -1)
+	if len(matches) == 0 {
+		return 0, errors.New("unable to locate startxref")
+	}
+
+	offset, err := strconv.Atoi(string(matches[len(matches)-1][1]))
+	if err != nil || offset >= len(pdf) {
+		return 0, errors.New("invalid startxref")
+	}
+
+	return offset, nil
+}
+
+func formatPdfDate(t time.Time) string {
+	_, offset := t.Zone()
+	sign := "+"
+	if offset < 0 {
+		sign = "-"
+		offset = -offset
+	}
+
+	return fmt.Sprintf("D:%s%s%02d'%02d'", t.Format("20060102150405"), sign, offset/3600, (offset%3600)/60)
+}
+
+// pdfTextString encodes s as a literal string, using UTF-16 when it is not plain ascii
+func pdfTextString(s string) string {
+	for _, r := range s {
+		if r > 126 {
+			s = types.EncodeUTF16String(s)
+			break
+		}
+	}
+
+	escaped, _ := types.Escape(s)
+	return "(" + *escaped + ")"
+}
@@ -0,0 +1,216 @@
+package main
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/hex"
+	"fmt"
+	"math/big"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/digitorus/pkcs7"
+	"github.com/pdfcpu/pdfcpu/pkg/api"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/model"
+)
+
+var signatureByteRangePattern = regexp.MustCompile(`/ByteRange \[0 (\d+) (\d+) (\d+)\s*\]`)
+
+// newTestPdf creates a pdf with pageCount A4 pages, each showing its page number
+func newTestPdf(t *testing.T, pageCount int) []byte {
+	t.Helper()
+
+	var pages []string
+	for pageNumber := 1; pageNumber <= pageCount; pageNumber++ {
+		pages = append(pages, fmt.Sprintf(`"%d": {"content": {"text": [{"value": "Page %d", "pos": [100, 700], "font": {"name": "Helvetica", "size": 24}}]}}`, pageNumber, pageNumber))
+	}
+
+	definition := `{"paper": "A4P", "origin": "LowerLeft", "pages": {` + strings.Join(pages, ",") + `}}`
+
+	var output bytes.Buffer
+	if err := api.Create(nil, strings.NewReader(definition), &output, nil); err != nil {
+		t.Fatalf("unable to create test pdf: %s", err)
+	}
+
+	return output.Bytes()
+}
+
+// withXrefTable rewrites pdf with a classic cross-reference table instead of a cross-reference stream
+func withXrefTable(t *testing.T, pdf []byte) []byte {
+	t.Helper()
+
+	conf := model.NewDefaultConfiguration()
+	conf.WriteObjectStream = false
+	conf.WriteXRefStream = false
+
+	var output bytes.Buffer
+	if err := api.Optimize(bytes.NewReader(pdf), &output, conf); err != nil {
+		t.Fatalf("unable to rewrite test pdf: %s", err)
+	}
+
+	if !bytes.Contains(output.Bytes(), []byte("\nxref\n")) {
+		t.Fatal("the rewritten test pdf has no cross-reference table")
+	}
+
+	return output.Bytes()
+}
+
+// newTestSigningIdentity creates a self-signed rsa identity
+func newTestSigningIdentity(t *testing.T) *SigningIdentity {
+	t.Helper()
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "Test Signer", Organization: []string{"Remote PDF Printer"}},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature | x509.KeyUsageContentCommitment,
+	}
+
+	certificateData, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	certificate, err := x509.ParseCertificate(certificateData)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return &SigningIdentity{Name: "test", Certificate: certificate, PrivateKey: key}
+}
+
+func TestSignPdf(t *testing.T) {
+	tests := []struct {
+		name      string
+		signature PdfSignature
+		xrefTable bool
+	}{
+		{name: "invisible", signature: PdfSignature{Reason: "Approved", Location: "Halifax"}},
+		{name: "visible", signature: PdfSignature{Visible: true, Page: 1, Rect: []float64{50, 50, 180, 40}, Reason: "Résumé reviewed"}},
+		{name: "xref table", signature: PdfSignature{}, xrefTable: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			pdfFile := filepath.Join(t.TempDir(), "signed.pdf")
+			original := newTestPdf(t, 2)
+			if test.xrefTable {
+				original = withXrefTable(t, original)
+			}
+			if err := os.WriteFile(pdfFile, original, 0640); err != nil {
+				t.Fatal(err)
+			}
+
+			identity := newTestSigningIdentity(t)
+			serverOptions := &ServerOptions{SigningIdentities: map[string]*SigningIdentity{"test": identity}}
+
+			details, err := signPdf(pdfFile, &test.signature, serverOptions)
+			if err != nil {
+				t.Fatalf("signPdf: %s", err)
+			}
+
+			if !details.Valid {
+				t.Errorf("signature reported invalid: %s %v", details.Status, details.Problems)
+			}
+
+			if details.Visible != test.signature.Visible {
+				t.Errorf("visible = %t, want %t", details.Visible, test.signature.Visible)
+			}
+
+			signed, err := os.ReadFile(pdfFile)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if !bytes.HasPrefix(signed, original) {
+				t.Error("the signature was not appended as an incremental update")
+			}
+
+			ctx, err := api.ReadContext(bytes.NewReader(signed), model.NewDefaultConfiguration())
+			if err != nil {
+				t.Fatalf("signed pdf does not parse: %s", err)
+			}
+
+			if err := api.ValidateContext(ctx); err != nil {
+				t.Fatalf("signed pdf does not validate: %s", err)
+			}
+
+			if ctx.PageCount != 2 {
+				t.Errorf("page count = %d, want 2", ctx.PageCount)
+			}
+
+			verifyByteRangeSignature(t, signed, identity)
+		})
+	}
+}
+
+// verifyByteRangeSignature checks that the byte range covers the file but the contents and that the pkcs7 signature
+// in the contents verifies over it
+func verifyByteRangeSignature(t *testing.T, signed []byte, identity *SigningIdentity) {
+	t.Helper()
+
+	matches := signatureByteRangePattern.FindSubmatch(signed)
+	if matches == nil {
+		t.Fatal("no byte range found")
+	}
+
+	byteRange := make([]int, 3)
+	for i := range byteRange {
+		byteRange[i], _ = strconv.Atoi(string(matches[i+1]))
+	}
+
+	contentsStart, contentsEnd, tailLength := byteRange[0], byteRange[1], byteRange[2]
+	if contentsEnd+tailLength != len(signed) {
+		t.Fatalf("byte range ends at %d, the file is %d bytes", contentsEnd+tailLength, len(signed))
+	}
+
+	if signed[contentsStart] != '<' || signed[contentsEnd-1] != '>' {
+		t.Fatal("the byte range gap is not the contents string")
+	}
+
+	cmsData, err := hex.DecodeString(string(signed[contentsStart+1 : contentsEnd-1]))
+	if err != nil {
+		t.Fatalf("contents is not hex: %s", err)
+	}
+
+	// The contents is padded with zeros after the signature
+	var cms asn1.RawValue
+	if _, err := asn1.Unmarshal(cmsData, &cms); err != nil {
+		t.Fatalf("contents is not a der signature: %s", err)
+	}
+
+	p7, err := pkcs7.Parse(cms.FullBytes)
+	if err != nil {
+		t.Fatalf("unable to parse signature: %s", err)
+	}
+
+	p7.Content = append(bytes.Clone(signed[:contentsStart]), signed[contentsEnd:]...)
+	if err := p7.Verify(); err != nil {
+		t.Fatalf("signature does not verify over the byte range: %s", err)
+	}
+
+	if signer := p7.GetOnlySigner(); signer == nil || !signer.Equal(identity.Certificate) {
+		t.Error("the signature was not made with the signing certificate")
+	}
+
+	// Changing a signed byte has to break the signature
+	p7.Content[len(p7.Content)/2] ^= 0xff
+	if err := p7.Verify(); err == nil {
+		t.Error("the signature verifies over modified content")
+	}
+}
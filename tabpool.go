@@ -0,0 +1,24 @@
+package main
+
+// TabPool limits how many Chrome tabs are rendering at the same time
+type TabPool struct {
+	tabs chan struct{}
+}
+
+func NewTabPool(size int) *TabPool {
+	return &TabPool{tabs: make(chan struct{}, size)}
+}
+
+// Acquire waits until a tab is available
+func (pool *TabPool) Acquire() {
+	pool.tabs <- struct{}{}
+}
+
+func (pool *TabPool) Release() {
+	<-pool.tabs
+}
+
+// Size is the number of tabs that may render at the same time
+func (pool *TabPool) Size() int {
+	return cap(pool.tabs)
+}
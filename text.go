@@ -0,0 +1,131 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+)
+
+type PdfTextRequest struct {
+	PdfSource
+	Words bool `json:"words" form:"words"`
+}
+
+type PdfTextResponse struct {
+	Text  string        `json:"text"`
+	Pages []PdfTextPage `json:"pages"`
+}
+
+// PdfTextPage is the text of a page. Its size and the word boxes are in pixels of the page preview image, of the first
+// preview size for /preview and /pdf/preview.
+type PdfTextPage struct {
+	Page   int           `json:"page"`
+	Width  int           `json:"width"`
+	Height int           `json:"height"`
+	Text   string        `json:"text"`
+	Words  []PdfTextWord `json:"words,omitempty"`
+}
+
+type PdfTextWord struct {
+	Text   string  `json:"text"`
+	X      float64 `json:"x"`
+	Y      float64 `json:"y"`
+	Width  float64 `json:"width"`
+	Height float64 `json:"height"`
+}
+
+// PdfTextLayout is a page as the PdfTextExtractor reports it, in points
+type PdfTextLayout struct {
+	Width  float64
+	Height float64
+	Words  []PdfTextWord
+}
+
+// extractPdfText returns the text of every page of pdfFile, with the page and word boxes scaled to the images of the
+// preview size. The word boxes are only extracted when words is set.
+func extractPdfText(pdfFile string, words bool, size PdfPreviewSize, options *ServerOptions) ([]PdfTextPage, error) {
+	if options.TextExtractor == nil {
+		return nil, errors.New("unable to extract pdf text: no text extractor is available, install poppler-utils")
+	}
+
+	texts, err := options.TextExtractor.Text(pdfFile)
+	if err != nil {
+		return nil, fmt.Errorf("unable to extract pdf text: %w", err)
+	}
+
+	// The word boxes take another pass of pdftotext, the page sizes alone are known without it
+	var layouts []PdfTextLayout
+	if words {
+		layouts, err = options.TextExtractor.Words(pdfFile)
+	} else {
+		layouts, err = getPdfPageLayouts(pdfFile, options)
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("unable to extract pdf text: %w", err)
+	}
+
+	pages := make([]PdfTextPage, len(layouts))
+	for index, layout := range layouts {
+		scale := size.scale(layout.Width, layout.Height)
+
+		pages[index] = PdfTextPage{
+			Page:   index + 1,
+			Width:  int(math.Ceil(layout.Width * scale)),
+			Height: int(math.Ceil(layout.Height * scale)),
+		}
+
+		if index < len(texts) {
+			pages[index].Text = texts[index]
+		}
+
+		if words {
+			pages[index].Words = make([]PdfTextWord, len(layout.Words))
+			for wordIndex, word := range layout.Words {
+				pages[index].Words[wordIndex] = PdfTextWord{
+					Text:   word.Text,
+					X:      roundPixels(word.X * scale),
+					Y:      roundPixels(word.Y * scale),
+					Width:  roundPixels(word.Width * scale),
+					Height: roundPixels(word.Height * scale),
+				}
+			}
+		}
+	}
+
+	return pages, nil
+}
+
+// getPdfPageLayouts returns the size of every page of pdfFile as it is displayed, without words
+func getPdfPageLayouts(pdfFile string, options *ServerOptions) ([]PdfTextLayout, error) {
+	pdfInfo, err := getPdfInfo(pdfFile, options)
+	if err != nil {
+		return nil, err
+	}
+
+	layouts := make([]PdfTextLayout, len(pdfInfo.PageSizes))
+	for index, pageSize := range pdfInfo.PageSizes {
+		layouts[index] = PdfTextLayout{Width: pageSize.Width, Height: pageSize.Height}
+		if (pageSize.Rotation%180+180)%180 == 90 {
+			layouts[index] = PdfTextLayout{Width: pageSize.Height, Height: pageSize.Width}
+		}
+	}
+
+	return layouts, nil
+}
+
+func roundPixels(value float64) float64 {
+	return math.Round(value*100) / 100
+}
+
+// joinPageText joins the text of every page with form feeds like pdftotext does
+func joinPageText(pages []PdfTextPage) string {
+	var text strings.Builder
+	for _, page := range pages {
+		text.WriteString(page.Text)
+		text.WriteString("\f")
+	}
+
+	return text.String()
+}
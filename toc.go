@@ -0,0 +1,199 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"html/template"
+	"os"
+	"regexp"
+	"strconv"
+
+	"github.com/chromedp/cdproto/page"
+	"github.com/chromedp/chromedp"
+	"github.com/pdfcpu/pdfcpu/pkg/api"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/model"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/types"
+)
+
+// Table of contents entries link to tocLinkPrefix<page>, Chrome keeps them as uri links which are replaced with
+// links to the page once the documents are merged
+const tocLinkPrefix = "https://toc.invalid/page/"
+
+var tocLinkPattern = regexp.MustCompile(`^` + regexp.QuoteMeta(tocLinkPrefix) + `(\d+)$`)
+
+// The number of pages the table of contents takes can change the page numbers it lists, which can change the number
+// of pages it takes. Give up after this many renders.
+const maxTocRenders = 4
+
+type PdfTableOfContents struct {
+	Title  string   `json:"title" form:"tableOfContentsTitle"`
+	Titles []string `json:"titles" form:"tableOfContentsTitles"`
+}
+
+type TableOfContentsEntry struct {
+	Title string
+	Page  int
+	Link  template.URL
+}
+
+type TableOfContentsData struct {
+	Title   string
+	Entries []TableOfContentsEntry
+}
+
+func loadTocTemplate(templatePath string) (*template.Template, error) {
+	templateBytes, err := os.ReadFile(templatePath)
+	if err != nil {
+		return nil, err
+	}
+
+	return template.New("toc").Parse(string(templateBytes))
+}
+
+// resolveComponentTitles picks the title of every component. Titles from the request win over the title reported by
+// the rendered document, then the pdf metadata.
+func resolveComponentTitles(requestTitles []string, renderedTitles []string, components []string, serverOptions *ServerOptions) ([]string, error) {
+	titles := make([]string, len(components))
+	for index, component := range components {
+		if index < len(requestTitles) {
+			titles[index] = requestTitles[index]
+		}
+
+		if titles[index] == "" && index < len(renderedTitles) {
+			titles[index] = renderedTitles[index]
+		}
+
+		if titles[index] == "" {
+			pdfInfo, err := getPdfInfo(component, serverOptions)
+			if err != nil {
+				return nil, err
+			}
+			titles[index] = pdfInfo.Metadata.Title
+		}
+
+		if titles[index] == "" {
+			titles[index] = fmt.Sprintf("Document %d", index+1)
+		}
+	}
+
+	return titles, nil
+}
+
+// buildTableOfContents renders the table of contents through Chrome and returns the pdf file along with its page
+// count. startPages returns the page every component starts on when the table of contents takes tocPages pages.
+func buildTableOfContents(toc *PdfTableOfContents, titles []string, startPages func(tocPages int) []int, printOptions *page.PrintToPDFParams, opts []chromedp.ContextOption, serverOptions *ServerOptions) (string, int, error) {
+	data := TableOfContentsData{Title: toc.Title}
+	if data.Title == "" {
+		data.Title = "Contents"
+	}
+
+	for _, title := range titles {
+		data.Entries = append(data.Entries, TableOfContentsEntry{Title: title})
+	}
+
+	tocPages := 1
+	for range maxTocRenders {
+		for index, startPage := range startPages(tocPages) {
+			data.Entries[index].Page = startPage
+			data.Entries[index].Link = template.URL(tocLinkPrefix + strconv.Itoa(startPage))
+		}
+
+		var html bytes.Buffer
+		if err := serverOptions.TocTemplate.Execute(&html, data); err != nil {
+			return "", 0, fmt.Errorf("unable to render table of contents template: %w", err)
+		}
+
+		pdfData, err := renderPdf(html.String(), printOptions, pdfRenderOptions{}, opts, serverOptions, nil)
+		if err != nil {
+			return "", 0, fmt.Errorf("unable to generate table of contents: %w", err)
+		}
+
+		pageCount, err := serverOptions.PdfEngine.PageCount(pdfData)
+		if err != nil {
+			return "", 0, fmt.Errorf("unable to read table of contents page count: %w", err)
+		}
+
+		if pageCount == tocPages {
+			tocFile, err := writePdfFile(pdfData, "*-toc.pdf", serverOptions)
+			if err != nil {
+				return "", 0, err
+			}
+
+			return tocFile, tocPages, nil
+		}
+
+		tocPages = pageCount
+	}
+
+	return "", 0, errors.New("unable to generate table of contents: the page count did not settle")
+}
+
+// linkTableOfContents replaces the placeholder uri links on the tocPages pages starting at tocStart with links to the
+// pages they refer to
+func linkTableOfContents(pdfFile string, tocStart int, tocPages int) error {
+	pdfData, err := os.ReadFile(pdfFile)
+	if err != nil {
+		return fmt.Errorf("unable to read pdf: %w", err)
+	}
+
+	conf := model.NewDefaultConfiguration()
+	ctx, err := api.ReadValidateAndOptimize(bytes.NewReader(pdfData), conf)
+	if err != nil {
+		return fmt.Errorf("unable to read pdf: %w", err)
+	}
+
+	for pageNumber := tocStart; pageNumber < tocStart+tocPages && pageNumber <= ctx.PageCount; pageNumber++ {
+		pageDict, _, _, err := ctx.PageDict(pageNumber, false)
+		if err != nil {
+			return err
+		}
+
+		annotations, err := ctx.DereferenceArray(pageDict["Annots"])
+		if err != nil {
+			return err
+		}
+
+		for _, annotationObject := range annotations {
+			annotation, err := ctx.DereferenceDict(annotationObject)
+			if err != nil || annotation == nil {
+				continue
+			}
+
+			action, err := ctx.DereferenceDict(annotation["A"])
+			if err != nil || action == nil {
+				continue
+			}
+
+			uri, err := ctx.DereferenceStringOrHexLiteral(action["URI"], model.V10, nil)
+			if err != nil {
+				continue
+			}
+
+			matches := tocLinkPattern.FindStringSubmatch(uri)
+			if matches == nil {
+				continue
+			}
+
+			target, _ := strconv.Atoi(matches[1])
+			if target < 1 || target > ctx.PageCount {
+				continue
+			}
+
+			targetRef, err := ctx.PageDictIndRef(target)
+			if err != nil {
+				return err
+			}
+
+			annotation.Delete("A")
+			annotation["Dest"] = types.Array{*targetRef, types.Name("Fit")}
+		}
+	}
+
+	var output bytes.Buffer
+	if err := api.Write(ctx, &output, conf); err != nil {
+		return fmt.Errorf("unable to write pdf: %w", err)
+	}
+
+	return os.WriteFile(pdfFile, output.Bytes(), 0640)
+}
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+
+	"github.com/pdfcpu/pdfcpu/pkg/api"
+)
+
+const (
+	TransformSelect         string = "select"
+	TransformExtract        string = "extract"
+	TransformRotate         string = "rotate"
+	TransformReorder        string = "reorder"
+	TransformDelete         string = "delete"
+	TransformSplit          string = "split"
+	TransformSplitBookmarks string = "splitBookmarks"
+)
+
+type PdfTransformRequest struct {
+	PdfSource
+	Download   bool                    `json:"download" form:"download"`
+	Operations []PdfTransformOperation `json:"operations" form:"-"`
+}
+
+type PdfTransformOperation struct {
+	Operation string `json:"operation"`
+	Pages     string `json:"pages"`
+	Order     []int  `json:"order"`
+	Rotation  int    `json:"rotation"`
+	Span      int    `json:"span"`
+}
+
+// transformPdf applies the operations in order, each one to every document produced so far. The resulting
+// documents are written to the pdfs directory.
+func transformPdf(pdfData []byte, operations []PdfTransformOperation, options *ServerOptions) ([]string, error) {
+	documents := [][]byte{pdfData}
+	for index, operation := range operations {
+		var transformed [][]byte
+		for _, document := range documents {
+			results, err := applyTransformOperation(document, &operation, options.PdfEngine)
+			if err != nil {
+				return nil, fmt.Errorf("operation %d (%s) failed: %w", index, operation.Operation, err)
+			}
+			transformed = append(transformed, results...)
+		}
+		documents = transformed
+	}
+
+	var outputFiles []string
+	for index, document := range documents {
+		tempFile, err := os.CreateTemp(*options.DirectoryMap[DirectoryKeyPdf], fmt.Sprintf("*-transformed-%d.pdf", index+1))
+		if err != nil {
+			return nil, errors.New("unable to create output file")
+		}
+
+		if err := os.WriteFile(tempFile.Name(), document, 0640); err != nil {
+			return nil, fmt.Errorf("unable to write output file: %w", err)
+		}
+
+		outputFiles = append(outputFiles, tempFile.Name())
+	}
+
+	return outputFiles, nil
+}
+
+func applyTransformOperation(pdfData []byte, operation *PdfTransformOperation, engine PdfEngine) ([][]byte, error) {
+	pages, err := api.ParsePageSelection(operation.Pages)
+	if err != nil {
+		return nil, fmt.Errorf("invalid page selection %q: %w", operation.Pages, err)
+	}
+
+	var result []byte
+	switch operation.Operation {
+	case TransformSelect, TransformExtract:
+		if len(pages) == 0 {
+			return nil, errors.New("pages is required")
+		}
+		result, err = engine.SelectPages(pdfData, pages)
+	case TransformReorder:
+		for _, page := range operation.Order {
+			pages = append(pages, strconv.Itoa(page))
+		}
+
+		if len(pages) == 0 {
+			return nil, errors.New("order or pages is required")
+		}
+		result, err = engine.CollectPages(pdfData, pages)
+	case TransformDelete:
+		if len(pages) == 0 {
+			return nil, errors.New("pages is required")
+		}
+		result, err = engine.RemovePages(pdfData, pages)
+	case TransformRotate:
+		if operation.Rotation == 0 || operation.Rotation%90 != 0 {
+			return nil, errors.New("rotation must be a multiple of 90")
+		}
+		result, err = engine.RotatePages(pdfData, operation.Rotation, pages)
+	case TransformSplit:
+		if operation.Span < 1 {
+			return nil, errors.New("span must be at least 1")
+		}
+		return engine.Split(pdfData, operation.Span)
+	case TransformSplitBookmarks:
+		return engine.Split(pdfData, 0)
+	default:
+		return nil, fmt.Errorf("unknown operation %q", operation.Operation)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return [][]byte{result}, nil
+}
@@ -19,7 +19,7 @@ func fileNameWithoutExtension(fileName string) string {
 
 func createDirectories(options *ServerOptions) {
 	options.DirectoryMap = make(map[string]*string)
-	for _, path := range [4]string{DirectoryKeyPdf, DirectoryKeySources, DirectoryKeyPreview, DirectoryKeyPng} {
+	for _, path := range [5]string{DirectoryKeyPdf, DirectoryKeySources, DirectoryKeyPreview, DirectoryKeyPng, DirectoryKeyArchive} {
 		fullPath := *options.RootDirectory + "/files/" + path
 		if !pathExists(fullPath) {
 			err := os.MkdirAll(fullPath, 0755)
@@ -37,4 +37,11 @@ func createDirectories(options *ServerOptions) {
 			panic("Unable to create cert directory: " + err.Error())
 		}
 	}
+
+	if options.CertDirectory != nil && !pathExists(*options.CertDirectory+"/trusted") {
+		err := os.MkdirAll(*options.CertDirectory+"/trusted", 0755)
+		if err != nil {
+			panic("Unable to create trusted cert directory: " + err.Error())
+		}
+	}
 }
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"path/filepath"
+	"strconv"
+)
+
+// CwebpEncoder converts images to WebP with cwebp from libwebp-tools
+type CwebpEncoder struct {
+	Path string
+}
+
+// NewCwebpEncoder returns nil when cwebp can not be found in binDirectory
+func NewCwebpEncoder(binDirectory string) *CwebpEncoder {
+	path := filepath.Join(binDirectory, "cwebp")
+	if !pathExists(path) {
+		return nil
+	}
+
+	return &CwebpEncoder{Path: path}
+}
+
+// Encode writes inputFile to outputFile as WebP, a quality of 0 uses the cwebp default
+func (encoder *CwebpEncoder) Encode(inputFile string, outputFile string, quality int) error {
+	cmdArgs := []string{"-quiet"}
+	if quality > 0 {
+		cmdArgs = append(cmdArgs, "-q", strconv.Itoa(quality))
+	}
+	cmdArgs = append(cmdArgs, inputFile, "-o", outputFile)
+
+	return runCommand(encoder.Path, cmdArgs...)
+}
+
+// Img2webpEncoder assembles animated WebP images with img2webp from libwebp-tools
+type Img2webpEncoder struct {
+	Path string
+}
+
+// NewImg2webpEncoder returns nil when img2webp can not be found in binDirectory
+func NewImg2webpEncoder(binDirectory string) *Img2webpEncoder {
+	path := filepath.Join(binDirectory, "img2webp")
+	if !pathExists(path) {
+		return nil
+	}
+
+	return &Img2webpEncoder{Path: path}
+}
+
+// Encode writes the frames to outputFile as an endlessly looping animation, every frame is shown for the duration in
+// milliseconds at the same index. A quality of 0 encodes the frames losslessly.
+func (encoder *Img2webpEncoder) Encode(frames []string, durations []int, outputFile string, quality int) error {
+	cmdArgs := []string{"-loop", "0"}
+	if quality > 0 {
+		cmdArgs = append(cmdArgs, "-lossy", "-q", strconv.Itoa(quality))
+	} else {
+		cmdArgs = append(cmdArgs, "-lossless")
+	}
+
+	for index, frame := range frames {
+		cmdArgs = append(cmdArgs, "-d", strconv.Itoa(durations[index]), frame)
+	}
+	cmdArgs = append(cmdArgs, "-o", outputFile)
+
+	return runCommand(encoder.Path, cmdArgs...)
+}